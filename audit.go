@@ -0,0 +1,87 @@
+package redisFallback
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type AuditEntry struct {
+	Time  int64  `json:"time"`
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Size  int64  `json:"size,omitempty"`
+	TTL   int64  `json:"ttl,omitempty"`
+	Mode  string `json:"mode"`
+	Actor string `json:"actor,omitempty"`
+}
+
+// * 記錄每次 Set / Del，寫入僅附加的稽核檔案或回呼
+type auditor struct {
+	mutex sync.Mutex
+	file  *os.File
+	fn    func(AuditEntry)
+	clock Clock
+}
+
+func newAuditor(o *Options) (*auditor, error) {
+	if o.AuditPath == "" && o.AuditFunc == nil {
+		return nil, nil
+	}
+
+	a := &auditor{fn: o.AuditFunc, clock: o.Clock}
+	if o.AuditPath != "" {
+		if err := os.MkdirAll(filepath.Dir(o.AuditPath), o.DirMode); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(o.AuditPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, o.FileMode)
+		if err != nil {
+			return nil, err
+		}
+		a.file = file
+	}
+	return a, nil
+}
+
+func (a *auditor) record(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	entry.Time = a.clock.Now().Unix()
+
+	if a.fn != nil {
+		a.fn(entry)
+	}
+
+	if a.file != nil {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		a.mutex.Lock()
+		a.file.Write(append(data, '\n'))
+		a.mutex.Unlock()
+	}
+}
+
+func (a *auditor) close() {
+	if a == nil || a.file == nil {
+		return
+	}
+	a.mutex.Lock()
+	a.file.Close()
+	a.mutex.Unlock()
+}
+
+// * 帶呼叫者資訊的 Set
+func (rf *RedisFallback) SetAs(actor string, key string, value interface{}, ttl time.Duration) error {
+	return rf.set(context.Background(), actor, PriorityNormal, key, value, ttl)
+}
+
+// * 帶呼叫者資訊的 Del
+func (rf *RedisFallback) DelAs(actor string, key string) error {
+	return rf.del(context.Background(), actor, key)
+}
@@ -0,0 +1,102 @@
+package redisFallback
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newBenchInstance(b *testing.B, port int, configure ...func(*Options)) *RedisFallback {
+	b.Helper()
+	dir := b.TempDir()
+	options := &Options{
+		DBPath:      dir + "/db",
+		TimeToWrite: time.Hour,
+		TimeToCheck: time.Hour,
+	}
+	for _, fn := range configure {
+		fn(options)
+	}
+	rf, err := New(Config{
+		Redis:   &Redis{Host: "127.0.0.1", Port: port},
+		Log:     &Log{Path: dir + "/logs"},
+		Options: options,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(rf.Close)
+	return rf
+}
+
+func newNormalBenchInstance(b *testing.B) *RedisFallback {
+	rf := newBenchInstance(b, 6379)
+	if err := rf.redis.Ping(context.Background()).Err(); err != nil {
+		b.Skip("Redis is not available on 127.0.0.1:6379")
+	}
+	return rf
+}
+
+func newFallbackBenchInstance(b *testing.B, configure ...func(*Options)) *RedisFallback {
+	// * port 1 is never a Redis server, start in fallback mode
+	return newBenchInstance(b, 1, configure...)
+}
+
+func BenchmarkSetNormal(b *testing.B) {
+	rf := newNormalBenchInstance(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rf.Set(fmt.Sprintf("bench:%d", i%1000), "value", time.Minute)
+	}
+}
+
+func BenchmarkGetNormal(b *testing.B) {
+	rf := newNormalBenchInstance(b)
+	rf.Set("bench:get", "value", time.Minute)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rf.Get("bench:get")
+	}
+}
+
+func BenchmarkSetFallback(b *testing.B) {
+	rf := newFallbackBenchInstance(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rf.Set(fmt.Sprintf("bench:%d", i%1000), "value", time.Minute)
+	}
+}
+
+func BenchmarkGetFallback(b *testing.B) {
+	rf := newFallbackBenchInstance(b)
+	rf.Set("bench:get", "value", time.Minute)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rf.Get("bench:get")
+	}
+}
+
+func BenchmarkWriterFlush(b *testing.B) {
+	b.Run("files", func(b *testing.B) {
+		benchmarkWriterFlush(b, newFallbackBenchInstance(b))
+	})
+	b.Run("segment", func(b *testing.B) {
+		benchmarkWriterFlush(b, newFallbackBenchInstance(b, func(o *Options) { o.SegmentWrites = true }))
+	})
+}
+
+func benchmarkWriterFlush(b *testing.B, rf *RedisFallback) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		rf.writer.mutex.Lock()
+		for j := 0; j < 1000; j++ {
+			key := fmt.Sprintf("bench:%d", j)
+			rf.writer.pending[key] = WriteRequest{Key: key, Data: Cache{Key: key, Data: "value", Timestamp: time.Now().Unix()}}
+		}
+		rf.writer.mutex.Unlock()
+		b.StartTimer()
+		rf.writer.write()
+	}
+}
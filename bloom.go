@@ -0,0 +1,104 @@
+package redisFallback
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultBloomBits   = 1 << 20 // 預設 bloom filter 位元數，約 128KB
+	defaultBloomHashes = 4       // 預設 bloom filter 雜湊次數
+)
+
+// * 記錄曾寫入本地檔案的金鑰，讓不存在的金鑰略過讀檔
+type bloomFilter struct {
+	mutex  sync.RWMutex
+	bits   []uint64
+	size   uint64
+	hashes int
+}
+
+func newBloomFilter(size uint64, hashes int) *bloomFilter {
+	return &bloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+func (b *bloomFilter) indexes(sum [16]byte) []uint64 {
+	h1 := binary.LittleEndian.Uint64(sum[0:8])
+	h2 := binary.LittleEndian.Uint64(sum[8:16])
+
+	list := make([]uint64, b.hashes)
+	for i := 0; i < b.hashes; i++ {
+		list[i] = (h1 + uint64(i)*h2) % b.size
+	}
+	return list
+}
+
+func (b *bloomFilter) add(sum [16]byte) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, i := range b.indexes(sum) {
+		b.bits[i/64] |= 1 << (i % 64)
+	}
+}
+
+func (b *bloomFilter) has(sum [16]byte) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for _, i := range b.indexes(sum) {
+		if b.bits[i/64]&(1<<(i%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) addKey(key string) {
+	b.add(md5.Sum([]byte(key)))
+}
+
+func (b *bloomFilter) hasKey(key string) bool {
+	return b.has(md5.Sum([]byte(key)))
+}
+
+// * 以既有檔案名稱（金鑰的 MD5）建立 bloom filter，預設分片無需讀取內容
+func (b *bloomFilter) load(config Config) {
+	folderPath := filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB))
+
+	filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+			return nil
+		}
+
+		// * Custom layouts do not encode the key in the file name, read it from the content
+		if config.Options.PathResolver != nil {
+			if data, err := os.ReadFile(path); err == nil {
+				if item, err := decodeCache(config, &marshalers{}, data); err == nil {
+					b.addKey(item.Key)
+				}
+			}
+			return nil
+		}
+
+		decoded, err := hex.DecodeString(strings.TrimSuffix(info.Name(), ".json"))
+		if err != nil || len(decoded) != md5.Size {
+			return nil
+		}
+
+		var sum [16]byte
+		copy(sum[:], decoded)
+		b.add(sum)
+		return nil
+	})
+}
@@ -0,0 +1,35 @@
+package redisFallback
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+)
+
+const bytesType = "[]uint8" // 以 []byte 寫入時記錄的型別名稱
+
+// * 取得 []byte 值，記憶體層直接回傳儲存的 slice（不複製，呼叫端不可修改）；以其他型別寫入的字串回傳 ErrType
+func (rf *RedisFallback) GetBytes(key string) ([]byte, error) {
+	result, err := rf.getDetailed(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := result.Value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		// * Only values written as []byte are base64 after passing through JSON (file or Redis)
+		if result.dataType != bytesType {
+			return nil, newOpError(rf.logger, "get", key, TierMemory, fmt.Errorf("%w: string is not []byte", ErrType))
+		}
+		// * Decoded on every read, writing it back could overwrite a concurrent Set
+		data, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, newOpError(rf.logger, "get", key, TierMemory, parseError(err))
+		}
+		return data, nil
+	default:
+		return nil, newOpError(rf.logger, "get", key, TierMemory, fmt.Errorf("%w: %T is not []byte", ErrType, result.Value))
+	}
+}
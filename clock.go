@@ -0,0 +1,42 @@
+package redisFallback
+
+import (
+	"time"
+)
+
+// * 可替換的時間來源，測試時可快轉 TTL 到期、清理與健康檢查，無需實際等待
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{ticker: time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+func (rf *RedisFallback) now() time.Time {
+	return rf.config.Options.Clock.Now()
+}
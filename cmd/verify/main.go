@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	rf "github.com/pardnchiu/go-redis-fallback"
+)
+
+// * 掃描本地檔案，回報過期、損毀與孤立的項目
+// * go run ./cmd/verify -path ./files/redisFallback/db -db 0 -action quarantine
+func main() {
+	path := flag.String("path", "./files/redisFallback/db", "fallback DBPath")
+	db := flag.Int("db", 0, "Redis DB number")
+	action := flag.String("action", "", "empty to report only, repair or quarantine")
+	flag.Parse()
+
+	report, err := rf.Verify(*path, *db, *action)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	// * Non-zero exit when problems were found and left in place
+	if report.Checked-report.Valid > report.Fixed {
+		os.Exit(1)
+	}
+}
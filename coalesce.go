@@ -0,0 +1,52 @@
+package redisFallback
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// * 同一金鑰同時間只發出一次 Redis 讀取，其餘呼叫等待並共用結果
+type inflight struct {
+	mutex sync.Mutex
+	calls map[string]*inflightCall
+}
+
+type inflightCall struct {
+	done   chan struct{}
+	result string
+	pttl   time.Duration
+	err    error
+}
+
+func (rf *RedisFallback) getCoalesced(ctx context.Context, key string) (string, time.Duration, error) {
+	rf.inflight.mutex.Lock()
+	if rf.inflight.calls == nil {
+		rf.inflight.calls = make(map[string]*inflightCall)
+	}
+	// * Another goroutine is already fetching this key
+	call, ok := rf.inflight.calls[key]
+	if !ok {
+		call = &inflightCall{done: make(chan struct{})}
+		rf.inflight.calls[key] = call
+
+		// * Detached from the caller, one caller giving up must not fail the others
+		fetchCtx := context.WithoutCancel(ctx)
+		go func() {
+			call.result, call.pttl, call.err = rf.getWithTTL(fetchCtx, key)
+
+			rf.inflight.mutex.Lock()
+			delete(rf.inflight.calls, key)
+			rf.inflight.mutex.Unlock()
+			close(call.done)
+		}()
+	}
+	rf.inflight.mutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	case <-call.done:
+		return call.result, call.pttl, call.err
+	}
+}
@@ -0,0 +1,79 @@
+package redisFallback
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	TimeFormatRFC3339   = "rfc3339"   // RFC3339 含奈秒，保留時區
+	TimeFormatUnixMilli = "unixmilli" // Unix 毫秒，還原為本地時區
+)
+
+// * 依 Options.TimeFormat 註冊 time.Time 的格式，讀取時還原為 time.Time 而非字串
+func (m *marshalers) registerTime(format string) {
+	var marshaler Marshaler
+	switch format {
+	case TimeFormatRFC3339:
+		marshaler = Marshaler{
+			Marshal: func(v interface{}) ([]byte, error) {
+				return []byte(v.(time.Time).Format(time.RFC3339Nano)), nil
+			},
+			Unmarshal: func(data []byte) (interface{}, error) {
+				return time.Parse(time.RFC3339Nano, string(data))
+			},
+		}
+	case TimeFormatUnixMilli:
+		marshaler = Marshaler{
+			Marshal: func(v interface{}) ([]byte, error) {
+				return []byte(strconv.FormatInt(v.(time.Time).UnixMilli(), 10)), nil
+			},
+			Unmarshal: func(data []byte) (interface{}, error) {
+				ms, err := strconv.ParseInt(string(data), 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				return time.UnixMilli(ms), nil
+			},
+		}
+	default:
+		return
+	}
+	m.list.Store(reflect.TypeOf(time.Time{}).String(), marshaler)
+}
+
+// * 註冊實作 encoding.TextMarshaler / TextUnmarshaler 的型別，例如 *big.Int、decimal.Decimal
+// * 以字串儲存，避免成為 JSON 數字而失去精度
+func (rf *RedisFallback) RegisterText(sample interface{}) error {
+	t := reflect.TypeOf(sample)
+	elem := t
+	if t.Kind() == reflect.Pointer {
+		elem = t.Elem()
+	}
+	if _, ok := reflect.New(elem).Interface().(encoding.TextUnmarshaler); !ok {
+		return rf.logger.Error(nil, fmt.Sprintf("*%s does not implement encoding.TextUnmarshaler", elem))
+	}
+
+	rf.RegisterMarshaler(sample, Marshaler{
+		Marshal: func(v interface{}) ([]byte, error) {
+			if m, ok := v.(encoding.TextMarshaler); ok {
+				return m.MarshalText()
+			}
+			return nil, fmt.Errorf("%T does not implement encoding.TextMarshaler", v)
+		},
+		Unmarshal: func(data []byte) (interface{}, error) {
+			ptr := reflect.New(elem)
+			if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText(data); err != nil {
+				return nil, err
+			}
+			if t.Kind() == reflect.Pointer {
+				return ptr.Interface(), nil
+			}
+			return ptr.Elem().Interface(), nil
+		},
+	})
+	return nil
+}
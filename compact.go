@@ -0,0 +1,140 @@
+package redisFallback
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const defaultCompactBatch = 1000 // 每次壓縮最多檢查的檔案數
+
+var errCompactBatchDone = errors.New("compact batch done")
+
+// * 定期掃描本地檔案，移除過期、無法解析或金鑰不符的檔案
+func (rf *RedisFallback) startCompaction() {
+	interval := rf.config.Options.TimeToCompact
+	if interval <= 0 {
+		return
+	}
+
+	ticker := rf.config.Options.Clock.NewTicker(interval)
+	rf.goroutine(func() {
+		cursor := ""
+		for {
+			select {
+			case <-rf.closed:
+				ticker.Stop()
+				return
+			case <-ticker.C():
+				cursor = rf.compact(cursor, defaultCompactBatch)
+			}
+		}
+	})
+}
+
+// * 從 cursor 之後繼續，最多檢查 limit 個檔案，回傳下次的 cursor
+func (rf *RedisFallback) compact(cursor string, limit int) string {
+	rf.writer.checkpoint()
+	folderPath := filepath.Join(rf.config.Options.DBPath, strconv.Itoa(rf.config.Redis.DB))
+
+	checked := 0
+	last := ""
+	err := filepath.WalkDir(folderPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			return nil
+		}
+		if path <= cursor {
+			return nil
+		}
+
+		if checked >= limit {
+			return errCompactBatchDone
+		}
+		checked++
+		last = path
+
+		if rf.isCompactable(path) {
+			if err := os.Remove(path); err != nil {
+				rf.logger.Error(err, "Failed to remove file")
+				return nil
+			}
+			rf.removeShardFolder(filepath.Dir(path), folderPath)
+		}
+		return nil
+	})
+
+	// * Finished the whole tree, start over next time
+	if err == nil {
+		return ""
+	}
+	return last
+}
+
+func (rf *RedisFallback) isCompactable(path string) bool {
+	item, status := classifyFile(rf.config, rf.marshalers, path, rf.now())
+	switch status {
+	case fileExpired:
+		rf.deleteCache(item.Key)
+		rf.index.remove(item.Key)
+		return true
+	case fileCorrupt, fileOrphaned:
+		return true
+	default:
+		return false
+	}
+}
+
+// * 復原前整理本地檔案：移除過期檔案，同一金鑰有多個檔案時只保留最新的值，並回傳每個金鑰的所有檔案路徑
+func (rf *RedisFallback) compactForRecovery(files []string) (map[string]Cache, map[string][]string, int) {
+	now := rf.now()
+	latest := make(map[string]Cache, len(files))
+	paths := make(map[string][]string, len(files))
+	dropped := 0
+	for _, file := range files {
+		item, status := classifyFile(rf.config, rf.marshalers, file, now)
+		switch status {
+		case fileUnreadable, fileCorrupt:
+			rf.logger.Info("Skipped unreadable file", file)
+			dropped++
+			continue
+		case fileExpired:
+			os.Remove(file)
+			dropped++
+			continue
+		case fileOrphaned:
+			// * Left behind by a previous path layout, still a candidate unless expired
+			if isExpired(item, now) {
+				os.Remove(file)
+				dropped++
+				continue
+			}
+		}
+
+		paths[item.Key] = append(paths[item.Key], file)
+		// * Duplicate of a key already seen, keep the newest write
+		if prev, ok := latest[item.Key]; ok {
+			dropped++
+			if prev.Timestamp >= item.Timestamp {
+				continue
+			}
+		}
+		latest[item.Key] = item
+	}
+	return latest, paths, dropped
+}
+
+// * 由下往上移除空的分片目錄
+func (rf *RedisFallback) removeShardFolder(path string, root string) {
+	for path != root && strings.HasPrefix(path, root) {
+		if !rf.removeFolder(path) {
+			return
+		}
+		path = filepath.Dir(path)
+	}
+}
@@ -0,0 +1,106 @@
+package redisFallback
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const CompressionGzip = "gzip" // 內建的 gzip 壓縮
+
+// * 壓縮後的本地檔案以 0x00 "rf" 開頭，後接壓縮器 id 的長度與 id；JSON 不會以 0x00 開頭
+var compressedMagic = []byte{0x00, 'r', 'f'}
+
+// * 本地檔案的壓縮方式，以 RegisterCompressor 註冊後於 Options.Compression 指定 id
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var compressors sync.Map
+
+// * 註冊壓縮器，id 會寫入檔案開頭，讀取時依此選擇解壓方式；已寫入的檔案需保留對應的註冊
+func RegisterCompressor(id string, c Compressor) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("Invalid compressor id %q", id)
+	}
+	compressors.Store(id, c)
+	return nil
+}
+
+func getCompressor(id string) (Compressor, error) {
+	if value, ok := compressors.Load(id); ok {
+		return value.(Compressor), nil
+	}
+	if id == CompressionGzip {
+		return gzipCompressor{}, nil
+	}
+	return nil, fmt.Errorf("Compressor %q is not registered", id)
+}
+
+// * 依 Options.Compression 壓縮並加上檔頭，未設定時回傳原始資料
+func compress(config Config, data []byte) ([]byte, error) {
+	id := config.Options.Compression
+	if id == "" {
+		return data, nil
+	}
+
+	c, err := getCompressor(id)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(compressedMagic)+1+len(id)+len(body))
+	out = append(out, compressedMagic...)
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+	return append(out, body...), nil
+}
+
+// * 沒有檔頭的資料視為未壓縮，啟用壓縮前寫入的檔案仍可讀取
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, compressedMagic) {
+		return data, nil
+	}
+
+	rest := data[len(compressedMagic):]
+	if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+		return nil, fmt.Errorf("Invalid compressed file header")
+	}
+	id := string(rest[1 : 1+int(rest[0])])
+
+	c, err := getCompressor(id)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decompress(rest[1+int(rest[0]):])
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,189 @@
+package redisFallback
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const counterType = "counter" // 以 Incr 系列寫入的計數器，Redis 中為原生整數
+
+type incrs struct {
+	mutex sync.Mutex
+}
+
+// * 計數器加一，回傳累加後的值
+func (rf *RedisFallback) Incr(key string) (int64, error) {
+	return rf.IncrBy(key, 1)
+}
+
+// * 計數器減一，回傳累加後的值
+func (rf *RedisFallback) Decr(key string) (int64, error) {
+	return rf.IncrBy(key, -1)
+}
+
+// * IncrByDetailed 的結果
+type IncrResult struct {
+	Value       int64  // 累加後的值
+	Tier        string // 計算的位置：redis / memory
+	Provisional bool   // 降級時本地沒有 Redis 的基準值，Value 只包含降級期間的累加，復原後才會與 Redis 合計
+}
+
+// * Redis 使用 INCRBY；降級時於本地累加並記錄差值，恢復後以差值 INCRBY 補回，不會覆蓋期間其他來源的累加
+// * 降級時本地沒有此計數器則從 0 起算，回傳值只是暫時的，需要判斷時使用 IncrByDetailed
+func (rf *RedisFallback) IncrBy(key string, n int64) (int64, error) {
+	result, err := rf.IncrByDetailed(key, n)
+	return result.Value, err
+}
+
+// * 同 IncrBy，額外回傳計算的位置與回傳值是否只是暫時的
+func (rf *RedisFallback) IncrByDetailed(key string, n int64) (IncrResult, error) {
+	if err := rf.validateKey("incr", key); err != nil {
+		return IncrResult{}, err
+	}
+	if err := rf.checkWritable("incr", key); err != nil {
+		return IncrResult{}, err
+	}
+
+	rf.metrics.sets.Add(1)
+	rf.namespaces.set(key)
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var value int64
+			value, err = rf.redis.IncrBy(ctx, key, n).Result()
+			// * Existing value is not a counter, retrying will not help
+			if isNotIntegerError(err) {
+				return IncrResult{}, newOpError(rf.logger, "incr", key, TierRedis, fmt.Errorf("%w: %s", ErrType, err))
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+			if err == nil {
+				if rf.config.Options.DisableMirror {
+					rf.deleteCache(key)
+				} else {
+					rf.mirrorCounter(key, value)
+				}
+				return IncrResult{Value: value, Tier: TierRedis}, nil
+			}
+		}
+
+		if err := rf.redisFailed("incr", key, err); err != nil {
+			return IncrResult{}, err
+		}
+	}
+
+	return rf.incrLocal(key, n)
+}
+
+func isNotIntegerError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not an integer")
+}
+
+// * 於本地累加並寫入檔案，Delta 為尚未補回 Redis 的差值；值等於 Delta 時本地沒有 Redis 的基準值
+func (rf *RedisFallback) incrLocal(key string, n int64) (IncrResult, error) {
+	rf.incrs.mutex.Lock()
+	defer rf.incrs.mutex.Unlock()
+
+	now := rf.now()
+	item := Cache{Key: key, Type: counterType, Timestamp: now.Unix()}
+	var value int64
+
+	if old, ok := rf.localItem(key); ok {
+		current, ok := counterValue(old)
+		if !ok {
+			return IncrResult{}, newOpError(rf.logger, "incr", key, TierMemory, fmt.Errorf("%w: %T is not a counter", ErrType, old.Data))
+		}
+		value = current
+		item.Delta = old.Delta
+		if old.TTL > 0 {
+			item.TTL = max(old.Timestamp+old.TTL-item.Timestamp, 1)
+		}
+	}
+
+	item.Data = value + n
+	item.Delta += n
+
+	if err := rf.setToMemory(context.Background(), key, item, PriorityNormal); err != nil {
+		return IncrResult{}, err
+	}
+	return IncrResult{Value: value + n, Tier: TierMemory, Provisional: value+n == item.Delta}, nil
+}
+
+// * 保留尚未補回的差值與本地的到期時間，Redis 的 INCR 不會改變 TTL
+func (rf *RedisFallback) mirrorCounter(key string, value int64) {
+	rf.incrs.mutex.Lock()
+	defer rf.incrs.mutex.Unlock()
+
+	item := Cache{Key: key, Data: value, Type: counterType, Timestamp: rf.now().Unix()}
+	if cached, ok := rf.cache.Load(key); ok {
+		if old := cached.(Cache); old.Type == counterType {
+			item.Delta = old.Delta
+			if old.TTL > 0 {
+				item.TTL = max(old.Timestamp+old.TTL-item.Timestamp, 1)
+			}
+		}
+	}
+	rf.storeCache(key, item)
+}
+
+// * 差值已補回 Redis，扣除後避免下次復原重複累加
+func (rf *RedisFallback) settleCounter(key string, delta int64) {
+	rf.incrs.mutex.Lock()
+	defer rf.incrs.mutex.Unlock()
+
+	// * Queued file write still carries the old delta
+	rf.writer.remove(key)
+
+	cached, ok := rf.cache.Load(key)
+	if !ok {
+		return
+	}
+	item := cached.(Cache)
+	if item.Type != counterType {
+		return
+	}
+	item.Delta -= delta
+	rf.storeCache(key, item)
+	// * Increments made during recovery, keep them on disk for the next one
+	if item.Delta != 0 {
+		rf.enqueueWrite(WriteRequest{Key: key, Data: item})
+	}
+}
+
+// * 數字經過 JSON（檔案）後為 float64 或 json.Number
+func counterValue(item Cache) (int64, bool) {
+	if item.Type != counterType {
+		return 0, false
+	}
+
+	switch v := item.Data.(type) {
+	case int64:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v), true
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// * INCR 寫入的值在 Redis 中為原生整數，不是 Cache 封裝
+func (rf *RedisFallback) parseCounter(key string, result string) (Cache, bool) {
+	n, err := strconv.ParseInt(result, 10, 64)
+	if err != nil {
+		return Cache{}, false
+	}
+	return Cache{Key: key, Data: n, Type: counterType, Timestamp: rf.now().Unix()}, true
+}
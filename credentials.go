@@ -0,0 +1,65 @@
+package redisFallback
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// * Redis 帳號密碼，每次建立新連線時讀取，輪替時不需重建實例
+type credentials struct {
+	mutex    sync.RWMutex
+	username string
+	password string
+}
+
+func (c *credentials) get() (string, string) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.username, c.password
+}
+
+// * 更新 Redis 帳號密碼，之後建立的連線使用新值，既有連線維持原本的驗證直到被連線池關閉
+// * 正常模式下先以新帳號密碼建立測試連線，驗證失敗時不套用；降級時直接套用，由健康檢查使用
+func (rf *RedisFallback) UpdateCredentials(username string, password string) error {
+	rf.mutex.RLock()
+	isHealth := rf.isHealth
+	rf.mutex.RUnlock()
+
+	if isHealth {
+		if err := rf.checkCredentials(username, password); err != nil {
+			return rf.logger.Error(err, "Failed to authenticate with new credentials")
+		}
+	}
+
+	rf.credentials.mutex.Lock()
+	rf.credentials.username = username
+	rf.credentials.password = password
+	rf.credentials.mutex.Unlock()
+
+	rf.logger.Info("Redis credentials updated")
+	return nil
+}
+
+// * 以新帳號密碼對每個節點建立單一連線並 ping
+func (rf *RedisFallback) checkCredentials(username string, password string) error {
+	ctx := context.Background()
+	check := func(opt redis.Options) error {
+		opt.CredentialsProvider = func() (string, string) {
+			return username, password
+		}
+		opt.PoolSize = 1
+		client := redis.NewClient(&opt)
+		defer client.Close()
+		return client.Ping(ctx).Err()
+	}
+
+	ring, ok := rf.redis.(*redis.Ring)
+	if !ok {
+		return check(*rf.redis.(*redis.Client).Options())
+	}
+	return ring.ForEachShard(ctx, func(ctx context.Context, client *redis.Client) error {
+		return check(*client.Options())
+	})
+}
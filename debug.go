@@ -0,0 +1,80 @@
+package redisFallback
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type DebugState struct {
+	Mode         string         `json:"mode"`
+	IsRecovering bool           `json:"is_recovering"`
+	Goroutines   int32          `json:"goroutines"`
+	Queue        DebugQueue     `json:"queue"`
+	Entries      int            `json:"entries"`
+	MemoryBytes  int64          `json:"memory_bytes"`
+	Shards       map[string]int `json:"shards"`
+}
+
+type DebugQueue struct {
+	Length   int   `json:"length"`
+	Capacity int   `json:"capacity"`
+	Merged   int64 `json:"merged"`
+	Dropped  int64 `json:"dropped"`
+}
+
+func (rf *RedisFallback) DebugState() DebugState {
+	rf.mutex.RLock()
+	mode := modeName(rf.isHealth)
+	rf.mutex.RUnlock()
+
+	state := DebugState{
+		Mode:         mode,
+		IsRecovering: rf.isRecovering.Load(),
+		Goroutines:   rf.goroutines.Load(),
+		Queue: DebugQueue{
+			Length:   rf.backlog(),
+			Capacity: rf.config.Options.MaxQueue,
+			Merged:   rf.writer.merged.Load(),
+			Dropped:  rf.writer.dropped.Load(),
+		},
+		MemoryBytes: rf.MemoryUsage(),
+		Shards:      make(map[string]int),
+	}
+
+	rf.cache.Range(func(key, value interface{}) bool {
+		// * shard = first layer of the fallback file path, follows PathResolver
+		state.Shards[shardName(rf.config, key.(string))]++
+		state.Entries++
+		return true
+	})
+
+	return state
+}
+
+// * 可選的除錯 handler，輸出內部狀態 JSON；?memory 時輸出記憶體層快照（金鑰依 KeyRedaction 遮蔽）
+func (rf *RedisFallback) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var body interface{} = rf.DebugState()
+		if r.URL.Query().Has("memory") {
+			list := rf.SnapshotMemory()
+			for i := range list {
+				list[i].Key = rf.logger.key(list[i].Key)
+			}
+			body = list
+		}
+
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func (rf *RedisFallback) goroutine(fn func()) {
+	rf.goroutines.Add(1)
+	go func() {
+		defer rf.goroutines.Add(-1)
+		fn()
+	}()
+}
@@ -0,0 +1,44 @@
+package redisFallback
+
+import (
+	"bytes"
+	"time"
+)
+
+// * 與記憶體層的值與 TTL 相同，且在 DedupWindow 內寫入過時略過此次寫入
+// * 只比對記憶體層，DisableMirror 的正常模式下不會略過
+func (rf *RedisFallback) isDuplicateSet(item Cache) bool {
+	window := rf.config.Options.DedupWindow
+	if window <= 0 {
+		return false
+	}
+
+	cached, ok := rf.cache.Load(item.Key)
+	if !ok {
+		return false
+	}
+	current := cached.(Cache)
+	if current.Type != item.Type || current.TTL != item.TTL {
+		return false
+	}
+	// * Outside the window the write also refreshes the expiration
+	if time.Duration(item.Timestamp-current.Timestamp)*time.Second >= window {
+		return false
+	}
+
+	encoder := rf.config.Options.Encoder
+	next, err := encoder.Marshal(item.Data)
+	if err != nil {
+		return false
+	}
+	prev, err := encoder.Marshal(current.Data)
+	if err != nil {
+		return false
+	}
+	if !bytes.Equal(prev, next) {
+		return false
+	}
+
+	rf.metrics.deduped.Add(1)
+	return true
+}
@@ -5,19 +5,61 @@ import (
 )
 
 func (rf *RedisFallback) Del(key string) error {
+	return rf.del(context.Background(), "", key)
+}
+
+// * 同 Del，ctx 取消或逾時時回傳 ctx 的錯誤
+func (rf *RedisFallback) DelCtx(ctx context.Context, key string) error {
+	return rf.del(ctx, "", key)
+}
+
+func (rf *RedisFallback) del(ctx context.Context, actor string, key string) error {
+	if err := rf.validateKey("del", key); err != nil {
+		return err
+	}
+	if err := rf.checkWritable("del", key); err != nil {
+		return err
+	}
+
 	rf.mutex.Lock()
-	isHealth := rf.isHealth
+	isHealth := rf.isHealth && !rf.isReadOnly.Load()
 	rf.mutex.Unlock()
 
-	rf.cache.Delete(key)
+	rf.metrics.dels.Add(1)
+	rf.auditor.record(AuditEntry{
+		Op:    "del",
+		Key:   key,
+		Mode:  modeName(isHealth),
+		Actor: actor,
+	})
+
+	rf.deleteCache(key)
 	rf.removeJSONFile(key)
 
-	if isHealth {
-		ctx := context.Background()
-		err := rf.redis.Del(ctx, key).Err()
-		if err != nil {
-			return rf.logger.Error(err, "Failed to delete")
+	// * Recovery must also delete the key from Redis, or the old value comes back
+	if !isHealth {
+		return rf.writeTombstone(key)
+	}
+
+	rf.markRecoveryWrite(key)
+	var err error
+	for i := 0; rf.canRetry(i); i++ {
+		err = rf.redis.Del(ctx, key).Err()
+		if err == nil {
+			return nil
+		}
+		// * Caller gave up, not a Redis failure
+		if ctx.Err() != nil {
+			return ctxError("del", key, TierRedis, ctx.Err())
 		}
+		if rf.stopRetry(err) {
+			break
+		}
+	}
+
+	// * Local copies are already gone, Redis must drop the key on recovery too
+	if err := rf.redisFailed("del", key, err); err != nil {
+		return err
 	}
-	return nil
+	return rf.writeTombstone(key)
 }
@@ -0,0 +1,128 @@
+package redisFallback
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultDetectErrorRate = 0.5 // 視窗中失敗比例達此值時切換至降級模式
+
+// * 以 go-redis hook 觀察所有指令的結果與延遲，失敗比例過高時不等重試用完即切換至降級模式
+type detector struct {
+	rf       *RedisFallback
+	window   int
+	rate     float64
+	latency  time.Duration
+	mutex    sync.Mutex
+	results  []bool // 環狀緩衝，true 為失敗
+	next     int
+	count    int
+	failures int
+	tripping atomic.Bool
+}
+
+func newDetector(rf *RedisFallback) *detector {
+	options := rf.config.Options
+	if options.DetectWindow <= 0 {
+		return nil
+	}
+	return &detector{
+		rf:      rf,
+		window:  options.DetectWindow,
+		rate:    options.DetectErrorRate,
+		latency: options.DetectLatency,
+		results: make([]bool, options.DetectWindow),
+	}
+}
+
+func (d *detector) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (d *detector) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := d.rf.now()
+		err := next(ctx, cmd)
+		d.observe(ctx, err, d.rf.now().Sub(start))
+		return err
+	}
+}
+
+func (d *detector) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := d.rf.now()
+		err := next(ctx, cmds)
+		elapsed := d.rf.now().Sub(start)
+		for _, cmd := range cmds {
+			d.observe(ctx, cmd.Err(), elapsed)
+		}
+		return err
+	}
+}
+
+// * 連線錯誤、逾時與超過 DetectLatency 的指令視為失敗；redis.Nil 與 Redis 回覆的錯誤代表 Redis 仍可用
+func (d *detector) observe(ctx context.Context, err error, elapsed time.Duration) {
+	// * Caller gave up, says nothing about Redis
+	if ctx.Err() != nil {
+		return
+	}
+
+	failed := isUnavailable(err) || d.latency > 0 && elapsed > d.latency
+
+	d.mutex.Lock()
+	if d.count == d.window && d.results[d.next] {
+		d.failures--
+	}
+	d.results[d.next] = failed
+	d.next = (d.next + 1) % d.window
+	d.count = min(d.count+1, d.window)
+	if failed {
+		d.failures++
+	}
+	trip := d.count == d.window && float64(d.failures) >= d.rate*float64(d.window)
+	d.mutex.Unlock()
+
+	if trip {
+		d.trip()
+	}
+}
+
+// * 連線錯誤與逾時；redis.Nil 與 Redis 回覆的錯誤代表 Redis 仍可用
+func isUnavailable(err error) bool {
+	var reply redis.Error
+	return err != nil && err != redis.Nil && !errors.As(err, &reply)
+}
+
+// * 指令可能在持有 rf.mutex 時執行，於另一個 goroutine 切換以免死結
+func (d *detector) trip() {
+	if !d.tripping.CompareAndSwap(false, true) {
+		return
+	}
+	d.rf.goroutine(func() {
+		defer d.tripping.Store(false)
+
+		d.rf.mutex.Lock()
+		if d.rf.isHealth {
+			d.rf.logger.Info("[detector] Switching to fallback mode")
+			d.rf.changeToFallbackMode("error rate exceeded")
+		}
+		d.rf.mutex.Unlock()
+		d.reset()
+	})
+}
+
+// * 恢復正常模式時清空，降級期間的失敗不影響之後的判斷
+func (d *detector) reset() {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	clear(d.results)
+	d.next, d.count, d.failures = 0, 0, 0
+	d.mutex.Unlock()
+}
@@ -0,0 +1,66 @@
+package redisFallback
+
+import (
+	"os"
+)
+
+const defaultDiskErrorBudget = 10 // 預設連續寫入失敗幾次後改為只使用記憶體
+
+// * 記錄檔案寫入結果，連續失敗達 DiskErrorBudget 次時改為只使用記憶體
+func (w *Writer) diskResult(err error) {
+	if err == nil {
+		w.diskFailures.Store(0)
+		return
+	}
+	if w.diskFailures.Add(1) >= int64(w.config.Options.DiskErrorBudget) && w.diskDown.CompareAndSwap(false, true) {
+		w.onDiskDown(err)
+	}
+}
+
+// * 暫停寫入本地檔案，待寫入資料保留在佇列，定期測試磁碟是否恢復
+func (rf *RedisFallback) changeToMemoryOnly(err error) {
+	rf.logger.Error(err, "Too many file write failures, switching to memory only")
+	rf.notify(EventDiskDown, err.Error())
+	rf.recordStats(statsEvent{Event: EventDiskDown})
+
+	ticker := rf.config.Options.Clock.NewTicker(rf.config.Options.TimeToCheck)
+	rf.goroutine(func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rf.closed:
+				return
+			case <-ticker.C():
+				if err := rf.probeDisk(); err != nil {
+					continue
+				}
+
+				rf.logger.Info("Disk is writable again, resuming file writes")
+				rf.writer.diskFailures.Store(0)
+				rf.writer.diskDown.Store(false)
+				rf.notify(EventDiskUp, "")
+				rf.recordStats(statsEvent{Event: EventDiskUp})
+				return
+			}
+		}
+	})
+}
+
+// * 建立並刪除暫存檔，確認 DBPath 可寫入
+func (rf *RedisFallback) probeDisk() error {
+	if err := os.MkdirAll(rf.config.Options.DBPath, rf.config.Options.DirMode); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(rf.config.Options.DBPath, ".probe-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write([]byte("{}")); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
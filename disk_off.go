@@ -0,0 +1,5 @@
+//go:build nofallbackdisk
+
+package redisFallback
+
+const fallbackDisk = false // 不寫入本地檔案，降級時只使用記憶體
@@ -0,0 +1,5 @@
+//go:build !nofallbackdisk
+
+package redisFallback
+
+const fallbackDisk = true // 以 nofallbackdisk 編譯時為 false，降級時只使用記憶體
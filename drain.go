@@ -0,0 +1,86 @@
+package redisFallback
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultDrainBatch = 100 // 每批排空的筆數
+
+type DrainReport struct {
+	Written   int `json:"written"`   // 寫入本地檔案的筆數
+	Synced    int `json:"synced"`    // 寫入 Redis 的筆數
+	Remaining int `json:"remaining"` // ctx 結束時仍在佇列中的筆數
+}
+
+// * 排空待寫入佇列，Redis 可寫入時寫入 Redis（失敗的改寫入檔案），否則寫入本地檔案
+// * 持續到佇列為空或 ctx 結束，每批完成時記錄進度，供重新開機前確認資料已落地
+func (rf *RedisFallback) DrainWriter(ctx context.Context) (DrainReport, error) {
+	report := DrainReport{}
+	for {
+		if ctx.Err() != nil {
+			report.Remaining = rf.backlog()
+			return report, ctxError("drain", "", TierFile, ctx.Err())
+		}
+
+		batch := rf.writer.take(defaultDrainBatch)
+		if len(batch) == 0 {
+			return report, nil
+		}
+
+		if rf.isHealthy() && !rf.isReadOnly.Load() {
+			batch = rf.drainToRedis(ctx, batch, &report)
+		}
+		if len(batch) == 0 {
+			rf.logger.Info("Draining writer", "synced", report.Synced, "written", report.Written)
+			continue
+		}
+
+		// * Memory only, put the batch back so nothing is lost
+		if rf.writer.diskDown.Load() {
+			for _, req := range batch {
+				rf.writer.push(req)
+			}
+			report.Remaining = rf.backlog()
+			return report, &OpError{Op: "drain", Tier: TierFile, Err: ErrDiskUnavailable}
+		}
+
+		rf.writer.flush(batch)
+		report.Written += len(batch)
+		rf.logger.Info("Draining writer", "synced", report.Synced, "written", report.Written)
+	}
+}
+
+// * 回傳未寫入 Redis、需改寫入檔案的項目
+func (rf *RedisFallback) drainToRedis(ctx context.Context, batch []WriteRequest, report *DrainReport) []WriteRequest {
+	pipe := rf.redis.Pipeline()
+	cmds := make([]redis.Cmder, len(batch))
+	var rest []WriteRequest
+	for i, req := range batch {
+		item, ok := req.Data.(Cache)
+		if !ok {
+			continue
+		}
+		cmd, err := rf.writeItem(ctx, pipe, req.Key, item)
+		if err != nil {
+			rest = append(rest, req)
+			continue
+		}
+		cmds[i] = cmd
+	}
+	pipe.Exec(ctx)
+
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		if cmd.Err() != nil {
+			rest = append(rest, batch[i])
+			continue
+		}
+		report.Synced++
+		rf.settle(batch[i].Key, batch[i].Data.(Cache))
+	}
+	return rest
+}
@@ -0,0 +1,64 @@
+//go:build !nonotifier
+
+package redisFallback
+
+import (
+	"fmt"
+	"net/smtp"
+	"sort"
+	"strings"
+)
+
+// * 預設內容附上模式切換當下的計數與狀態
+func (m *RedisFallback) sendEmail(ip string, reason string, snapshot map[string]int64) {
+	if m.config.Email == nil {
+		return
+	}
+
+	tag := "[Redis Fallback]"
+	if m.config.Name != "" {
+		tag = fmt.Sprintf("[Redis Fallback: %s]", m.config.Name)
+	}
+
+	subject := fmt.Sprintf("%s %s is unavailable", tag, ip)
+	if m.config.Email.Subject != nil {
+		str := (*m.config.Email.Subject)(ip, reason)
+		if str != "" {
+			subject = str
+		}
+	}
+	body := fmt.Sprintf("%s %s is unavailable, running in %s", tag, ip, reason)
+	if len(snapshot) > 0 {
+		names := make([]string, 0, len(snapshot))
+		for name := range snapshot {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		lines := make([]string, len(names))
+		for i, name := range names {
+			lines[i] = fmt.Sprintf("%s: %d", name, snapshot[name])
+		}
+		body += "\r\n\r\n" + strings.Join(lines, "\r\n")
+	}
+	if m.config.Email.Body != nil {
+		str := (*m.config.Email.Body)(ip, reason)
+		if str != "" {
+			body = str
+		}
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nCc: %s\r\nSubject: %s\r\n\r\n%s",
+		m.config.Email.From,
+		strings.Join(m.config.Email.To, ","),
+		strings.Join(m.config.Email.CC, ","),
+		subject,
+		body)
+
+	auth := smtp.PlainAuth("", m.config.Email.Username, m.config.Email.Password, m.config.Email.Host)
+	addr := fmt.Sprintf("%s:%d", m.config.Email.Host, m.config.Email.Port)
+
+	err := smtp.SendMail(addr, auth, m.config.Email.From, m.config.Email.To, []byte(msg))
+	if err != nil {
+		m.logger.Error(err, "Failed to send email")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build nonotifier
+
+package redisFallback
+
+// * 以 nonotifier 編譯時不寄送 Email，Config.Email 會被忽略，OnEvent 仍可使用
+func (m *RedisFallback) sendEmail(ip string, reason string, snapshot map[string]int64) {}
@@ -0,0 +1,21 @@
+package redisFallback
+
+import (
+	"encoding/json"
+)
+
+// * 可替換的 JSON 編碼器，例如 jsoniter.ConfigCompatibleWithStandardLibrary 或 sonic.ConfigStd
+type Encoder interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type stdEncoder struct{}
+
+func (stdEncoder) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdEncoder) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,54 @@
+package redisFallback
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound         = errors.New("Not found")
+	ErrParse            = errors.New("Failed to parse")
+	ErrType             = errors.New("Type mismatch")
+	ErrInvalidKey       = errors.New("Invalid key")
+	ErrDiskUnavailable  = errors.New("Disk is unavailable, memory only")
+	ErrRedisUnavailable = errors.New("Redis is unavailable")
+	ErrNilValue         = errors.New("Nil value")
+	ErrFallbackExpired  = errors.New("Fallback mode exceeded MaxFallback, writes are rejected")
+	ErrBufferFull       = errors.New("Buffer is full")
+	ErrQuotaExceeded    = errors.New("Namespace quota exceeded")
+)
+
+// * 帶有操作、金鑰與儲存層的錯誤，可用 errors.Is / errors.As 判斷
+type OpError struct {
+	Op   string // 操作名稱，例如 get / set / del
+	Key  string // 金鑰
+	Tier string // 儲存層：redis / memory / file
+	Err  error  // 原始錯誤
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("%s %q [%s]: %v", e.Op, e.Key, e.Tier, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+func newOpError(logger *logger, op, key, tier string, err error) error {
+	e := &OpError{Op: op, Key: key, Tier: tier, Err: err}
+	// * Log with the redacted key, return the original key to the caller
+	logger.Error(nil, (&OpError{Op: op, Key: logger.key(key), Tier: tier, Err: err}).Error())
+	return e
+}
+
+// * 呼叫端取消或逾時，不記錄日誌
+func ctxError(op, key, tier string, err error) error {
+	return &OpError{Op: op, Key: key, Tier: tier, Err: err}
+}
+
+func parseError(err error) error {
+	if err == nil {
+		return ErrParse
+	}
+	return fmt.Errorf("%w: %w", ErrParse, err)
+}
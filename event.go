@@ -0,0 +1,114 @@
+package redisFallback
+
+import (
+	"sync"
+)
+
+const (
+	EventFallback = "fallback"  // 切換至降級模式
+	EventNormal   = "normal"    // 恢復正常模式，本地資料開始於背景同步
+	EventDiskDown = "disk_down" // 本地檔案寫入連續失敗，改為只使用記憶體
+	EventDiskUp   = "disk_up"   // 本地檔案恢復可寫入
+
+	EventFallbackExpired = "fallback_expired" // 降級持續超過 MaxFallback
+
+	EventRecoveryProgress = "recovery_progress" // 背景同步每批完成
+	EventRecovered        = "recovered"         // 背景同步完成，本地檔案已清除
+)
+
+// * 狀態變化通知，透過 Options.OnEvent 接收
+type Event struct {
+	Time    int64            `json:"time"`
+	Name    string           `json:"name,omitempty"` // Config.Name
+	Type    string           `json:"type"`
+	Message string           `json:"message,omitempty"`
+	Metrics map[string]int64 `json:"metrics,omitempty"` // 進入或離開降級模式時的計數與狀態
+}
+
+// * 降級持續超過 NotifyAfter 才通知，短暫中斷自動復原時不發出
+func (rf *RedisFallback) notifyFallback(snapshot map[string]int64) {
+	after := rf.config.Options.NotifyAfter
+	if after <= 0 {
+		rf.alertFallback(snapshot)
+		return
+	}
+
+	since := rf.modeSince.Load()
+	ticker := rf.config.Options.Clock.NewTicker(after)
+	rf.goroutine(func() {
+		defer ticker.Stop()
+		select {
+		case <-rf.closed:
+		case <-ticker.C():
+			// * Still the same outage
+			if !rf.isHealthy() && rf.modeSince.Load() == since {
+				rf.alertFallback(snapshot)
+			}
+		}
+	})
+}
+
+func (rf *RedisFallback) alertFallback(snapshot map[string]int64) {
+	rf.alerted.Store(true)
+	rf.notifyMetrics(EventFallback, "", snapshot)
+	go rf.sendEmail(rf.redisAddress(), "fallback mode", snapshot)
+}
+
+// * 只有已通知過的降級才通知恢復
+func (rf *RedisFallback) notifyNormal(snapshot map[string]int64) {
+	if rf.alerted.Swap(false) || rf.config.Options.NotifyAfter <= 0 {
+		rf.notifyMetrics(EventNormal, "", snapshot)
+	}
+}
+
+func (rf *RedisFallback) notify(eventType string, message string) {
+	rf.notifyMetrics(eventType, message, nil)
+}
+
+// * OnEvent 依序於另一個 goroutine 呼叫：通知可能在持有 rf.mutex 時發出，回呼中呼叫 rf 的方法不會死結
+type events struct {
+	mutex   sync.Mutex
+	pending []Event
+	running bool
+}
+
+func (rf *RedisFallback) notifyMetrics(eventType string, message string, snapshot map[string]int64) {
+	if rf.config.Options.OnEvent == nil {
+		return
+	}
+	event := Event{
+		Time:    rf.now().Unix(),
+		Name:    rf.config.Name,
+		Type:    eventType,
+		Message: message,
+		Metrics: snapshot,
+	}
+
+	rf.events.mutex.Lock()
+	rf.events.pending = append(rf.events.pending, event)
+	if rf.events.running {
+		rf.events.mutex.Unlock()
+		return
+	}
+	rf.events.running = true
+	rf.events.mutex.Unlock()
+
+	rf.goroutine(rf.dispatchEvents)
+}
+
+// * 送完佇列中的通知後結束，下次通知時再啟動
+func (rf *RedisFallback) dispatchEvents() {
+	for {
+		rf.events.mutex.Lock()
+		if len(rf.events.pending) == 0 {
+			rf.events.running = false
+			rf.events.mutex.Unlock()
+			return
+		}
+		event := rf.events.pending[0]
+		rf.events.pending = rf.events.pending[1:]
+		rf.events.mutex.Unlock()
+
+		rf.config.Options.OnEvent(event)
+	}
+}
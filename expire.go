@@ -0,0 +1,118 @@
+package redisFallback
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// * 批次更新存活時間，Redis 以單一 pipeline 送出 EXPIRE 並同步更新本地的 TTL；ttl <= 0 代表移除到期時間
+// * 回傳失敗的金鑰與錯誤
+func (rf *RedisFallback) ExpireMany(list map[string]time.Duration) map[string]error {
+	errs := make(map[string]error)
+
+	pending := make(map[string]time.Duration, len(list))
+	for key, ttl := range list {
+		if err := rf.validateKey("expire", key); err != nil {
+			errs[key] = err
+			continue
+		}
+		if err := rf.checkWritable("expire", key); err != nil {
+			errs[key] = err
+			continue
+		}
+		pending[key] = ttl
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		pending = rf.expireManyInRedis(pending, errs)
+	}
+
+	// * Fallback mode, update memory and local files
+	for key, ttl := range pending {
+		if err := rf.expireLocal(key, ttl, true); err != nil {
+			errs[key] = err
+		}
+	}
+	return errs
+}
+
+// * 回傳未在 Redis 完成、需改為更新本地的金鑰
+func (rf *RedisFallback) expireManyInRedis(pending map[string]time.Duration, errs map[string]error) map[string]time.Duration {
+	ctx := context.Background()
+
+	var err error
+	for i := 0; len(pending) > 0 && rf.canRetry(i); i++ {
+		pipe := rf.redis.Pipeline()
+		cmds := make(map[string]*redis.BoolCmd, len(pending))
+		// * PERSIST also returns false when the key has no TTL, check existence separately
+		exists := make(map[string]*redis.IntCmd)
+		for key, ttl := range pending {
+			if ttl > 0 {
+				cmds[key] = pipe.Expire(ctx, key, ttl)
+			} else {
+				exists[key] = pipe.Exists(ctx, key)
+				cmds[key] = pipe.Persist(ctx, key)
+			}
+		}
+		pipe.Exec(ctx)
+
+		// * Retry only the keys that failed
+		failed := make(map[string]time.Duration)
+		for key, cmd := range cmds {
+			ok, cmdErr := cmd.Result()
+			if isReplyError(cmdErr) {
+				errs[key] = newOpError(rf.logger, "expire", key, TierRedis, cmdErr)
+				continue
+			}
+			if cmdErr != nil {
+				err = cmdErr
+				failed[key] = pending[key]
+				continue
+			}
+			if pending[key] <= 0 {
+				ok = exists[key].Val() > 0
+			}
+			if !ok {
+				errs[key] = newOpError(rf.logger, "expire", key, TierRedis, ErrNotFound)
+				continue
+			}
+			rf.expireLocal(key, pending[key], false)
+		}
+		pending = failed
+		if rf.stopRetry(err) {
+			break
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	rf.redisFailed("expiremany", "", err)
+	return pending
+}
+
+// * persist 為 false 時只更新記憶體層的副本，為 true 時連同本地檔案一起更新
+func (rf *RedisFallback) expireLocal(key string, ttl time.Duration, persist bool) error {
+	now := rf.now()
+	// * -1: no expiration
+	pttl := ttl
+	if ttl <= 0 {
+		pttl = -1
+	}
+
+	if !persist {
+		if cached, ok := rf.cache.Load(key); ok {
+			rf.storeCache(key, applyRemainingTTL(cached.(Cache), pttl, now))
+		}
+		return nil
+	}
+
+	item, ok := rf.localItem(key)
+	if !ok {
+		return newOpError(rf.logger, "expire", key, TierFile, ErrNotFound)
+	}
+	return rf.setToMemory(context.Background(), key, applyRemainingTTL(item, pttl, now), PriorityNormal)
+}
@@ -0,0 +1,68 @@
+package redisFallback
+
+import (
+	"path"
+	"sync"
+	"time"
+)
+
+// * 清理迴圈移除到期金鑰時呼叫，old 為到期前的值
+// * 回傳非 nil 的值時以 ttl 重新寫入，讓指定的金鑰保持在快取中；回傳 nil 時只作為通知
+type ExpireFunc func(key string, old interface{}) (value interface{}, ttl time.Duration, err error)
+
+type expireHooks struct {
+	mutex sync.RWMutex
+	list  []expireHook
+}
+
+type expireHook struct {
+	pattern string
+	fn      ExpireFunc
+}
+
+// * 以 path.Match 格式註冊，例如 user:*；多個規則符合時使用最先註冊的
+func (rf *RedisFallback) OnExpire(pattern string, fn ExpireFunc) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	rf.expireHooks.mutex.Lock()
+	defer rf.expireHooks.mutex.Unlock()
+	rf.expireHooks.list = append(rf.expireHooks.list, expireHook{pattern: pattern, fn: fn})
+	return nil
+}
+
+func (rf *RedisFallback) expireHook(key string) ExpireFunc {
+	rf.expireHooks.mutex.RLock()
+	defer rf.expireHooks.mutex.RUnlock()
+
+	for _, hook := range rf.expireHooks.list {
+		if ok, _ := path.Match(hook.pattern, key); ok {
+			return hook.fn
+		}
+	}
+	return nil
+}
+
+// * 清理迴圈釋放鎖之後執行，loader 較慢時不會阻擋其他讀寫
+func (rf *RedisFallback) runExpireHooks(expired []Cache) {
+	for _, item := range expired {
+		fn := rf.expireHook(item.Key)
+		if fn == nil {
+			continue
+		}
+
+		value, ttl, err := fn(item.Key, item.Data)
+		if err != nil {
+			rf.logger.Error(err, "Failed to reload expired key", rf.logger.key(item.Key))
+			continue
+		}
+		// * Notification only
+		if value == nil {
+			continue
+		}
+		if err := rf.Set(item.Key, value, ttl); err != nil {
+			rf.logger.Error(err, "Failed to rehydrate expired key", rf.logger.key(item.Key))
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package redisFallback
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// * 手動推進的時鐘，Advance 時觸發到期的 Ticker
+type fakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+	stopped  atomic.Bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	t := &fakeTicker{c: make(chan time.Time, 1), interval: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// * 與 time.Ticker 相同，接收端來不及時略過該次
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.stopped.Load() && !t.next.After(c.now) {
+			select {
+			case t.c <- c.now:
+			default:
+			}
+			t.next = t.next.Add(t.interval)
+		}
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.stopped.Store(true)
+}
+
+var errInjected = errors.New("injected: connection refused")
+
+// * down 為 true 時所有 Redis 指令失敗，模擬 Redis 中斷；failAfter 為 true 時指令執行後才回傳錯誤
+type faultHook struct {
+	down      atomic.Bool
+	failAfter atomic.Bool
+}
+
+func (h *faultHook) BeforeRedisOp(ctx context.Context, op string) error {
+	if h.down.Load() {
+		return errInjected
+	}
+	return nil
+}
+
+func (h *faultHook) AfterRedisOp(ctx context.Context, op string, err error) error {
+	if h.failAfter.Load() {
+		return errInjected
+	}
+	return err
+}
+
+func (h *faultHook) BeforeFileWrite(key string) error {
+	return nil
+}
+
+// * 只支援本套件用到的字串、雜湊與有序集合指令的 RESP2 伺服器
+type fakeRedis struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	values   map[string]string
+	hashes   map[string]map[string]string
+	zsets    map[string]map[string]float64
+	expireAt map[string]time.Time
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{
+		listener: listener,
+		values:   make(map[string]string),
+		hashes:   make(map[string]map[string]string),
+		zsets:    make(map[string]map[string]float64),
+		expireAt: make(map[string]time.Time),
+	}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeRedis) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeRedis) get(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func (s *fakeRedis) hash(key string) map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fields := make(map[string]string, len(s.hashes[key]))
+	for field, value := range s.hashes[key] {
+		fields[field] = value
+	}
+	return fields
+}
+
+func (s *fakeRedis) zscore(key string, member string) (float64, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	score, ok := s.zsets[key][member]
+	return score, ok
+}
+
+func (s *fakeRedis) zadd(key string, member string, score float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.zsets[key] == nil {
+		s.zsets[key] = make(map[string]float64)
+	}
+	s.zsets[key][member] = score
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		writer.WriteString(s.exec(args))
+		// * Pipelines arrive together, reply once the buffer is drained
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func (s *fakeRedis) exec(args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT", "CLIENT":
+		return "+OK\r\n"
+	case "GET":
+		if _, ok := s.hashes[args[1]]; ok {
+			return wrongType
+		}
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(value)
+	case "SET":
+		s.values[args[1]] = args[2]
+		delete(s.hashes, args[1])
+		delete(s.expireAt, args[1])
+		for i := 3; i+1 < len(args); i++ {
+			if strings.EqualFold(args[i], "EXAT") {
+				sec, _ := strconv.ParseInt(args[i+1], 10, 64)
+				s.expireAt[args[1]] = time.Unix(sec, 0)
+			}
+		}
+		return "+OK\r\n"
+	case "HSET":
+		if _, ok := s.values[args[1]]; ok {
+			return wrongType
+		}
+		fields, ok := s.hashes[args[1]]
+		if !ok {
+			fields = make(map[string]string)
+			s.hashes[args[1]] = fields
+		}
+		added := 0
+		for i := 2; i+1 < len(args); i += 2 {
+			if _, ok := fields[args[i]]; !ok {
+				added++
+			}
+			fields[args[i]] = args[i+1]
+		}
+		return ":" + strconv.Itoa(added) + "\r\n"
+	case "HDEL":
+		if _, ok := s.values[args[1]]; ok {
+			return wrongType
+		}
+		removed := 0
+		for _, field := range args[2:] {
+			if _, ok := s.hashes[args[1]][field]; ok {
+				removed++
+			}
+			delete(s.hashes[args[1]], field)
+		}
+		if len(s.hashes[args[1]]) == 0 {
+			delete(s.hashes, args[1])
+		}
+		return ":" + strconv.Itoa(removed) + "\r\n"
+	case "HGETALL":
+		if _, ok := s.values[args[1]]; ok {
+			return wrongType
+		}
+		reply := "*" + strconv.Itoa(len(s.hashes[args[1]])*2) + "\r\n"
+		for field, value := range s.hashes[args[1]] {
+			reply += bulk(field) + bulk(value)
+		}
+		return reply
+	case "DEL":
+		removed := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				removed++
+			}
+			if _, ok := s.hashes[key]; ok {
+				removed++
+			}
+			delete(s.values, key)
+			delete(s.hashes, key)
+			delete(s.zsets, key)
+			delete(s.expireAt, key)
+		}
+		return ":" + strconv.Itoa(removed) + "\r\n"
+	case "ZADD":
+		if s.zsets[args[1]] == nil {
+			s.zsets[args[1]] = make(map[string]float64)
+		}
+		i, gt := 2, false
+		if strings.EqualFold(args[i], "GT") {
+			i, gt = i+1, true
+		}
+		added := 0
+		for ; i+1 < len(args); i += 2 {
+			score, _ := strconv.ParseFloat(args[i], 64)
+			old, ok := s.zsets[args[1]][args[i+1]]
+			if !ok {
+				added++
+			}
+			if !ok || !gt || score > old {
+				s.zsets[args[1]][args[i+1]] = score
+			}
+		}
+		return ":" + strconv.Itoa(added) + "\r\n"
+	case "ZINCRBY":
+		if s.zsets[args[1]] == nil {
+			s.zsets[args[1]] = make(map[string]float64)
+		}
+		delta, _ := strconv.ParseFloat(args[2], 64)
+		s.zsets[args[1]][args[3]] += delta
+		return bulk(strconv.FormatFloat(s.zsets[args[1]][args[3]], 'g', -1, 64))
+	case "SCAN":
+		// * One page with every matching key
+		pattern := "*"
+		for i := 2; i+1 < len(args); i++ {
+			if strings.EqualFold(args[i], "MATCH") {
+				pattern = args[i+1]
+			}
+		}
+		var keys []string
+		for key := range s.values {
+			if ok, _ := path.Match(pattern, key); ok {
+				keys = append(keys, key)
+			}
+		}
+		reply := "*2\r\n" + bulk("0") + "*" + strconv.Itoa(len(keys)) + "\r\n"
+		for _, key := range keys {
+			reply += bulk(key)
+		}
+		return reply
+	case "PTTL":
+		if _, ok := s.values[args[1]]; !ok {
+			return ":-2\r\n"
+		}
+		at, ok := s.expireAt[args[1]]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return ":" + strconv.FormatInt(max(time.Until(at).Milliseconds(), 1), 10) + "\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+const wrongType = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+
+func bulk(value string) string {
+	return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+}
+
+// * 背景的模式切換與復原以 goroutine 執行，輪詢直到條件成立
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,415 @@
+package redisFallback
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type testEnv struct {
+	rf    *RedisFallback
+	redis *fakeRedis
+	hook  *faultHook
+	clock *fakeClock
+}
+
+func newTestEnv(t *testing.T, down bool, configure ...func(*Options)) *testEnv {
+	t.Helper()
+	env := &testEnv{
+		redis: newFakeRedis(t),
+		hook:  &faultHook{},
+		clock: newFakeClock(),
+	}
+	env.hook.down.Store(down)
+
+	dir := t.TempDir()
+	options := &Options{
+		DBPath:      dir + "/db",
+		TimeToWrite: time.Hour,
+		TimeToCheck: time.Minute,
+		Clock:       env.clock,
+		Hook:        env.hook,
+	}
+	for _, fn := range configure {
+		fn(options)
+	}
+	rf, err := New(Config{
+		Redis:   &Redis{Host: "127.0.0.1", Port: env.redis.port()},
+		Log:     &Log{Path: dir + "/logs"},
+		Options: options,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rf.Close)
+	env.rf = rf
+	return env
+}
+
+// * Redis 恢復後推進一次健康檢查，等待背景同步完成
+func (env *testEnv) recover(t *testing.T) {
+	t.Helper()
+	env.hook.down.Store(false)
+	env.clock.Advance(env.rf.config.Options.TimeToCheck)
+	eventually(t, func() bool {
+		return env.rf.isHealthy() && !env.rf.isRecovering.Load()
+	}, "recovery did not finish")
+}
+
+func TestSwitchToFallbackMode(t *testing.T) {
+	env := newTestEnv(t, false)
+	rf := env.rf
+
+	if err := rf.Set("online", "a", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := env.redis.get("online"); !ok {
+		t.Fatal("online write did not reach Redis")
+	}
+
+	env.hook.down.Store(true)
+	if err := rf.Set("offline", "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	if rf.isHealthy() {
+		t.Fatal("still in normal mode after retries were exhausted")
+	}
+	if got := rf.metrics.fallbacks.Load(); got != 1 {
+		t.Fatalf("fallbacks = %d, want 1", got)
+	}
+
+	result, err := rf.GetDetailed("offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Value != "b" || result.Tier != TierMemory || !result.Stale {
+		t.Fatalf("GetDetailed = %+v, want b from memory", result)
+	}
+}
+
+func TestRecoverySyncsOfflineWrites(t *testing.T) {
+	env := newTestEnv(t, true)
+	rf := env.rf
+
+	if rf.isHealthy() {
+		t.Fatal("started in normal mode while Redis was down")
+	}
+	if err := rf.Set("offline", "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	path := getPath(rf.config, "offline").filepath
+	rf.writer.flushAll()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("offline write was not spooled: %v", err)
+	}
+
+	env.recover(t)
+
+	if _, ok := env.redis.get("offline"); !ok {
+		t.Fatal("offline write was not synced to Redis")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("local file kept after recovery: %v", err)
+	}
+	if _, ok := rf.dirty.Load("offline"); ok {
+		t.Fatal("dirty mark kept after recovery")
+	}
+}
+
+func TestReplyErrorKeepsNormalMode(t *testing.T) {
+	env := newTestEnv(t, false)
+	rf := env.rf
+
+	// * The fake server answers -ERR to list commands
+	var reply redis.Error
+	if _, err := rf.LRange("list", 0, -1); !errors.As(err, &reply) {
+		t.Fatalf("LRange = %v, want the Redis reply error", err)
+	}
+	if _, err := rf.RPush("list", "a"); !errors.As(err, &reply) {
+		t.Fatalf("RPush = %v, want the Redis reply error", err)
+	}
+	if !rf.isHealthy() {
+		t.Fatal("switched to fallback mode on a reply error")
+	}
+}
+
+func TestDelDuringFallbackWritesTombstone(t *testing.T) {
+	env := newTestEnv(t, false)
+	rf := env.rf
+
+	if err := rf.Set("gone", "a", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	env.hook.down.Store(true)
+	if err := rf.Del("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if rf.isHealthy() {
+		t.Fatal("still in normal mode after DEL failed")
+	}
+	if _, err := rf.Get("gone"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Del = %v, want ErrNotFound", err)
+	}
+	if _, ok := env.redis.get("gone"); !ok {
+		t.Fatal("Redis lost the key while it was down")
+	}
+
+	env.recover(t)
+
+	if _, ok := env.redis.get("gone"); ok {
+		t.Fatal("tombstone was not replayed as DEL")
+	}
+	if _, ok := rf.tombstones.Load("gone"); ok {
+		t.Fatal("tombstone kept after recovery")
+	}
+}
+
+// * 復原期間的 HSET 只更新部分欄位，降級期間的欄位與刪除仍須同步
+func TestHashWriteDuringRecoveryKeepsOfflineFields(t *testing.T) {
+	env := newTestEnv(t, false)
+	rf := env.rf
+
+	if err := rf.HSet("h", map[string]interface{}{"a": "1", "b": "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env.hook.down.Store(true)
+	if _, err := rf.HDel("h", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if rf.isHealthy() {
+		t.Fatal("still in normal mode after retries were exhausted")
+	}
+	if err := rf.HSetField("h", "d", "4"); err != nil {
+		t.Fatal(err)
+	}
+
+	// * Redis is back and recovery has started but not synced the key yet
+	env.hook.down.Store(false)
+	rf.mutex.Lock()
+	rf.isHealth = true
+	rf.mutex.Unlock()
+	rf.isRecovering.Store(true)
+	if err := rf.HSetField("h", "c", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rf.recoverySkip.Load("h"); ok {
+		t.Fatal("hash write during recovery skipped the offline changes")
+	}
+
+	rf.recover()
+
+	fields := env.redis.hash("h")
+	if _, ok := fields["b"]; ok {
+		t.Fatalf("offline HDEL was not synced: %v", fields)
+	}
+	for _, field := range []string{"a", "c", "d"} {
+		if _, ok := fields[field]; !ok {
+			t.Fatalf("field %s missing after recovery: %v", field, fields)
+		}
+	}
+}
+
+func TestHashWrongTypeKeepsNormalMode(t *testing.T) {
+	env := newTestEnv(t, false)
+	rf := env.rf
+
+	if err := rf.Set("k", "a", 0); err != nil {
+		t.Fatal(err)
+	}
+	var reply redis.Error
+	if _, err := rf.HGetAll("k"); !errors.As(err, &reply) {
+		t.Fatalf("HGetAll = %v, want WRONGTYPE", err)
+	}
+	if err := rf.HSetField("k", "f", "v"); !errors.As(err, &reply) {
+		t.Fatalf("HSetField = %v, want WRONGTYPE", err)
+	}
+	if !rf.isHealthy() {
+		t.Fatal("switched to fallback mode on WRONGTYPE")
+	}
+
+	// * Fallback reads report the hash operation
+	env.hook.down.Store(true)
+	var opErr *OpError
+	if _, err := rf.HGetAll("missing"); !errors.As(err, &opErr) || opErr.Op != "hgetall" || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("HGetAll = %v, want hgetall not found", err)
+	}
+	if _, err := rf.HGetField("missing", "f"); !errors.As(err, &opErr) || opErr.Op != "hget" || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("HGetField = %v, want hget not found", err)
+	}
+}
+
+func TestDelPrefix(t *testing.T) {
+	env := newTestEnv(t, false)
+	rf := env.rf
+
+	if _, err := rf.DelPrefix(""); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("DelPrefix(\"\") = %v, want ErrInvalidKey", err)
+	}
+
+	for _, key := range []string{"a:1", "a:2", "b:1", "p:1"} {
+		if err := rf.Set(key, "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count, err := rf.DelPrefix("a:"); err != nil || count != 2 {
+		t.Fatalf("DelPrefix = %d, %v, want 2", count, err)
+	}
+	if _, ok := env.redis.get("a:1"); ok {
+		t.Fatal("a:1 kept in Redis")
+	}
+	if _, ok := env.redis.get("b:1"); !ok {
+		t.Fatal("b:1 deleted")
+	}
+
+	// * Offline deletes must reach Redis on recovery
+	env.hook.down.Store(true)
+	if count, err := rf.DelPrefix("p:"); err != nil || count != 1 {
+		t.Fatalf("DelPrefix = %d, %v, want 1", count, err)
+	}
+	if rf.isHealthy() {
+		t.Fatal("still in normal mode after retries were exhausted")
+	}
+	env.recover(t)
+	if _, ok := env.redis.get("p:1"); ok {
+		t.Fatal("p:1 came back from Redis after recovery")
+	}
+}
+
+// * 本地沒有分數時 ZIncrBy 只記錄差值，復原時加在 Redis 的分數上
+func TestZIncrByOfflineAddsToRedisScore(t *testing.T) {
+	env := newTestEnv(t, true)
+	rf := env.rf
+
+	// * Written by another client, never mirrored locally
+	env.redis.zadd("board", "a", 10)
+
+	score, err := rf.ZIncrBy("board", 5, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != 5 {
+		t.Fatalf("ZIncrBy = %v, want the local 5", score)
+	}
+	if _, err := rf.ZAdd("board", map[string]float64{"b": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	env.recover(t)
+	if score, _ := env.redis.zscore("board", "a"); score != 15 {
+		t.Fatalf("Redis score = %v, want 15", score)
+	}
+	if score, _ := env.redis.zscore("board", "b"); score != 1 {
+		t.Fatalf("Redis score = %v, want 1", score)
+	}
+
+	// * The next recovery must not replay the increment or the partial score
+	env.hook.down.Store(true)
+	if _, err := rf.ZAdd("board", map[string]float64{"c": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if rf.isHealthy() {
+		t.Fatal("still in normal mode after retries were exhausted")
+	}
+	env.recover(t)
+	if score, _ := env.redis.zscore("board", "a"); score != 15 {
+		t.Fatalf("Redis score after second recovery = %v, want 15", score)
+	}
+}
+
+func TestPipelineHookSetsCommandErrors(t *testing.T) {
+	env := newTestEnv(t, false)
+	rf := env.rf
+
+	env.hook.failAfter.Store(true)
+	ctx := context.Background()
+	pipe := rf.redis.Pipeline()
+	get := pipe.Get(ctx, "a")
+	set := pipe.Set(ctx, "b", "v", 0)
+	if _, err := pipe.Exec(ctx); !errors.Is(err, errInjected) {
+		t.Fatalf("Exec = %v, want the injected error", err)
+	}
+	for _, cmd := range []redis.Cmder{get, set} {
+		if !errors.Is(cmd.Err(), errInjected) {
+			t.Fatalf("%s Err = %v, want the injected error", cmd.Name(), cmd.Err())
+		}
+	}
+}
+
+func TestQuotaRejectsOfflineWrites(t *testing.T) {
+	env := newTestEnv(t, true, func(o *Options) {
+		o.Namespaces = map[string]string{"session": "session:"}
+		o.Quotas = map[string]Quota{"session": {MaxOfflineWrites: 1}}
+	})
+	rf := env.rf
+
+	if err := rf.Set("session:1", "a", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.Set("session:2", "b", 0); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("second offline write = %v, want ErrQuotaExceeded", err)
+	}
+	// * Other namespaces are not limited
+	if err := rf.Set("user:1", "c", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newClockInstance(t *testing.T, clock *fakeClock) *RedisFallback {
+	t.Helper()
+	dir := t.TempDir()
+	rf, err := New(Config{
+		// * port 1 is never a Redis server, start in fallback mode
+		Redis: &Redis{Host: "127.0.0.1", Port: 1},
+		Log:   &Log{Path: dir + "/logs"},
+		Options: &Options{
+			DBPath:      dir + "/db",
+			TimeToWrite: time.Hour,
+			TimeToCheck: time.Hour,
+			Clock:       clock,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rf.Close)
+	return rf
+}
+
+func TestTTLExpiresWithClock(t *testing.T) {
+	clock := newFakeClock()
+	rf := newClockInstance(t, clock)
+
+	if err := rf.Set("short", "a", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rf.Get("short"); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(11 * time.Second)
+	if _, err := rf.Get("short"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after TTL = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryCleanupRunsOnClock(t *testing.T) {
+	clock := newFakeClock()
+	rf := newClockInstance(t, clock)
+
+	if err := rf.Set("short", "a", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// * One cleanup tick, no real wait
+	clock.Advance(30 * time.Second)
+	eventually(t, func() bool {
+		_, ok := rf.cache.Load("short")
+		return !ok
+	}, "expired key kept in memory after the cleanup tick")
+}
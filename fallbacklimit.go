@@ -0,0 +1,54 @@
+package redisFallback
+
+import (
+	"fmt"
+)
+
+const (
+	FallbackFailOpen   = "open"   // 超過 MaxFallback 後仍持續使用本地儲存
+	FallbackFailClosed = "closed" // 超過 MaxFallback 後拒絕寫入，避免與 Redis 的差異無限擴大
+)
+
+// * 降級持續超過 MaxFallback 時升級通知，FailClosed 時開始拒絕寫入
+func (rf *RedisFallback) scheduleFallbackLimit() {
+	limit := rf.config.Options.MaxFallback
+	if limit <= 0 {
+		return
+	}
+
+	since := rf.modeSince.Load()
+	ticker := rf.config.Options.Clock.NewTicker(limit)
+	rf.goroutine(func() {
+		defer ticker.Stop()
+		select {
+		case <-rf.closed:
+		case <-ticker.C():
+			// * Still the same outage
+			if !rf.isHealthy() && rf.modeSince.Load() == since {
+				rf.expireFallback()
+			}
+		}
+	})
+}
+
+func (rf *RedisFallback) expireFallback() {
+	limit := rf.config.Options.MaxFallback
+	message := fmt.Sprintf("fallback mode exceeded %s, writes are still accepted", limit)
+	if rf.config.Options.FallbackPolicy == FallbackFailClosed {
+		rf.failClosed.Store(true)
+		message = fmt.Sprintf("fallback mode exceeded %s, writes are rejected", limit)
+	}
+
+	rf.logger.Error(nil, message)
+	snapshot := rf.metricsSnapshot()
+	rf.notifyMetrics(EventFallbackExpired, message, snapshot)
+	go rf.sendEmail(rf.redisAddress(), message, snapshot)
+}
+
+// * FailClosed 且降級超過 MaxFallback 時回傳 ErrFallbackExpired，恢復正常模式後解除
+func (rf *RedisFallback) checkWritable(op string, key string) error {
+	if rf.failClosed.Load() {
+		return &OpError{Op: op, Key: key, Tier: TierMemory, Err: ErrFallbackExpired}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package redisFallback
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	FlushMemory = "memory" // 清空記憶體層
+	FlushDisk   = "disk"   // 清空本地檔案與待寫入佇列
+	FlushRedis  = "redis"  // 對設定的 Redis DB 執行 FLUSHDB
+	FlushAll    = "all"    // 以上全部
+)
+
+type FlushReport struct {
+	Memory int   `json:"memory"` // 移除的記憶體層金鑰數
+	Disk   int   `json:"disk"`   // 移除的本地檔案數
+	Redis  int64 `json:"redis"`  // FLUSHDB 前的 DBSIZE
+}
+
+// * 依 scope 清除資料，confirm 必須與 scope 相同，避免以變數誤傳造成清除
+func (rf *RedisFallback) Flush(scope string, confirm string) (FlushReport, error) {
+	report := FlushReport{}
+	if scope != FlushMemory && scope != FlushDisk && scope != FlushRedis && scope != FlushAll {
+		return report, fmt.Errorf("Unknown flush scope: %s", scope)
+	}
+	if confirm != scope {
+		return report, fmt.Errorf("Flush %s requires confirm to be %q", scope, scope)
+	}
+
+	// * Checked first so a failed Redis flush leaves local data untouched
+	if scope == FlushRedis || scope == FlushAll {
+		if !rf.isHealthy() || rf.isReadOnly.Load() {
+			return report, &OpError{Op: "flush", Tier: TierRedis, Err: ErrRedisUnavailable}
+		}
+
+		ctx := context.Background()
+		size, err := rf.redis.DBSize(ctx).Result()
+		if err != nil {
+			return report, newOpError(rf.logger, "flush", "", TierRedis, err)
+		}
+		if err := rf.redis.FlushDB(ctx).Err(); err != nil {
+			return report, newOpError(rf.logger, "flush", "", TierRedis, err)
+		}
+		report.Redis = size
+	}
+
+	if scope == FlushDisk || scope == FlushAll {
+		report.Disk = rf.flushDisk()
+	}
+
+	if scope == FlushMemory || scope == FlushAll {
+		rf.cache.Range(func(key, value interface{}) bool {
+			rf.deleteCache(key.(string))
+			report.Memory++
+			return true
+		})
+	}
+
+	rf.logger.Info("Flushed", scope, "memory", report.Memory, "disk", report.Disk, "redis", report.Redis)
+	return report, nil
+}
+
+// * 待寫入佇列一併清除，避免清除後又被寫回檔案
+func (rf *RedisFallback) flushDisk() int {
+	rf.writer.mutex.Lock()
+	rf.writer.pending = make(map[string]WriteRequest)
+	rf.writer.pendingBytes = 0
+	rf.writer.mutex.Unlock()
+
+	// * Hold off direct file writes until the walk is done
+	rf.writer.folderMutex.Lock()
+	defer rf.writer.folderMutex.Unlock()
+
+	removed := 0
+	folderPath := filepath.Join(rf.config.Options.DBPath, strconv.Itoa(rf.config.Redis.DB))
+	filepath.WalkDir(folderPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			rf.logger.Error(err, "Failed to remove file")
+			return nil
+		}
+		removed++
+		return nil
+	})
+
+	rf.writer.clearSegments()
+	rf.writer.replicaClear()
+	rf.index.clear()
+	return removed
+}
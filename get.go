@@ -2,106 +2,259 @@ package redisFallback
 
 import (
 	"context"
-	"encoding/json"
 	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
+type GetResult struct {
+	Value    interface{} // 取得的值
+	Tier     string      // 值的來源層：redis / memory / file
+	Stale    bool        // 降級期間由本地取得，可能不是最新的值
+	dataType string      // 寫入時的型別名稱，GetBytes 據此判斷字串是否為 base64 的 []byte
+}
+
 func (rf *RedisFallback) Get(key string) (interface{}, error) {
+	return rf.GetCtx(context.Background(), key)
+}
+
+// * 同 Get，ctx 取消或逾時時停止重試並回傳 ctx 的錯誤，不會因此切換至降級模式
+func (rf *RedisFallback) GetCtx(ctx context.Context, key string) (interface{}, error) {
+	result, err := rf.getDetailed(ctx, key)
+	return result.Value, err
+}
+
+// * 同 Get，額外回傳來源層與是否可能過時
+func (rf *RedisFallback) GetDetailed(key string) (GetResult, error) {
+	return rf.getDetailed(context.Background(), key)
+}
+
+func (rf *RedisFallback) getDetailed(ctx context.Context, key string) (GetResult, error) {
 	rf.mutex.RLock()
 	isHealth := rf.isHealth
 	rf.mutex.RUnlock()
 
+	rf.metrics.gets.Add(1)
+
+	if err := rf.validateKey("get", key); err != nil {
+		rf.metrics.misses.Add(1)
+		return GetResult{}, err
+	}
+
+	var item Cache
+	var tier string
+	var err error
 	if isHealth {
-		return rf.getFromRedis(key)
+		if rf.config.Options.HedgedRead {
+			item, tier, err = rf.getHedged(ctx, key)
+		} else {
+			item, tier, err = rf.getFromRedis(ctx, key)
+		}
+	} else {
+		item, tier, err = rf.getFromMemory(ctx, key)
 	}
-	return rf.getFromMemory(key)
-}
 
-func (rf *RedisFallback) getFromRedis(key string) (interface{}, error) {
-	ctx := context.Background()
+	rf.namespaces.read(key, err)
+	if err != nil {
+		rf.metrics.misses.Add(1)
+		return GetResult{}, err
+	}
+	rf.touchHot(key)
+
+	// * Local copies are only authoritative while Redis is reachable
+	rf.mutex.RLock()
+	stale := tier != TierRedis && !rf.isHealth
+	rf.mutex.RUnlock()
+
+	return GetResult{Value: item.Data, Tier: tier, Stale: stale, dataType: item.Type}, nil
+}
 
+func (rf *RedisFallback) getFromRedis(ctx context.Context, key string) (Cache, string, error) {
 	// * Result does not exist or error
 	// * Check if the item exists in cache
 	if cached, ok := rf.cache.Load(key); ok {
 		item := cached.(Cache)
 
 		// * Item is expired
-		if isExpired(item) {
-			rf.cache.Delete(key)
+		if isExpired(item, rf.now()) {
+			rf.deleteCache(key)
 			rf.removeJSONFile(key)
 
-			return nil, rf.logger.Error(nil, "Not found")
+			return Cache{}, "", newOpError(rf.logger, "get", key, TierMemory, ErrNotFound)
 		}
 
 		go rf.syncToRedis(key, item)
 
-		return item.Data, nil
+		rf.metrics.hit(TierMemory)
+		return item, TierMemory, nil
 	}
 
-	for i := 0; i < rf.config.Option.MaxRetry; i++ {
-		result, err := rf.redis.Get(ctx, key).Result()
+	var err error
+	for i := 0; rf.canRetry(i); i++ {
+		var result string
+		var pttl time.Duration
+		result, pttl, err = rf.getCoalesced(ctx, key)
+		// * Caller gave up, not a Redis failure
+		if ctx.Err() != nil {
+			return Cache{}, "", ctxError("get", key, TierRedis, ctx.Err())
+		}
+		// * Key does not exist in Redis
+		if err == redis.Nil {
+			// * Backlog not synced yet, the key may still be in a local file
+			if rf.isRecovering.Load() {
+				item, err := rf.loadFromFile(key)
+				return item, TierFile, err
+			}
+			return Cache{}, "", newOpError(rf.logger, "get", key, TierRedis, ErrNotFound)
+		}
+		if rf.stopRetry(err) {
+			break
+		}
 		// * Result exists and no error
 		if err == nil {
-			var item Cache
-			// * Parse the JSON data
-			if json.Unmarshal([]byte(result), &item) == nil {
+			if item, ok := rf.parseRedisValue(key, result); ok {
+				item = applyRemainingTTL(item, pttl, rf.now())
 				// * Add to memory cache
-				rf.cache.Store(key, item)
-				return item.Data, nil
+				rf.repairLocal(key, item)
+				rf.metrics.hit(TierRedis)
+				return item, TierRedis, nil
 			}
 		}
 	}
 
-	rf.logger.Info("[getFromRedis] Switching to fallback mode")
-	rf.mutex.Lock()
-	rf.changeToFallbackMode()
-	rf.mutex.Unlock()
+	if err := rf.redisFailed("get", key, err); err != nil {
+		return Cache{}, "", err
+	}
+
+	return rf.getFromMemory(ctx, key)
+}
+
+// * GET 與 PTTL 同一次往返取得，記憶體層使用 Redis 實際剩餘的存活時間
+func (rf *RedisFallback) getWithTTL(ctx context.Context, key string) (string, time.Duration, error) {
+	pipe := rf.redis.Pipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	pipe.Exec(ctx)
 
-	return rf.getFromMemory(key)
+	result, err := get.Result()
+	if err != nil {
+		return "", 0, err
+	}
+	return result, pttl.Val(), nil
 }
 
-func (rf *RedisFallback) getFromMemory(key string) (interface{}, error) {
+// * Redis 的 TTL 可能已被外部修改，以實際剩餘時間調整 TTL（Timestamp 保持寫入時間）
+func applyRemainingTTL(item Cache, pttl time.Duration, now time.Time) Cache {
+	// * -1: no expiration, -2: key does not exist
+	if pttl < 0 {
+		if pttl == -1 {
+			item.TTL = 0
+		}
+		return item
+	}
+
+	expireAt := now.Add(pttl + time.Second - 1).Unix()
+	item.TTL = expireAt - item.Timestamp
+	if item.TTL <= 0 {
+		item.TTL = 1
+	}
+	return item
+}
+
+// * Redis 與本地檔案使用相同的 Cache 封裝格式
+func (rf *RedisFallback) marshalCache(cache Cache) ([]byte, error) {
+	return encodeCache(rf.config, rf.marshalers, cache)
+}
+
+func (rf *RedisFallback) parseRedisValue(key string, result string) (Cache, bool) {
+	// * Parse the JSON data
+	item, err := decodeCache(rf.config, rf.marshalers, []byte(result))
+	if err != nil {
+		return rf.parseCounter(key, result)
+	}
+	return item, true
+}
+
+func (rf *RedisFallback) getFromMemory(ctx context.Context, key string) (Cache, string, error) {
 	if result, ok := rf.cache.Load(key); ok {
 		item := result.(Cache)
 
 		// * Item is expired
-		if isExpired(item) {
-			rf.cache.Delete(key)
+		if isExpired(item, rf.now()) {
+			rf.deleteCache(key)
 
-			return nil, rf.logger.Error(nil, "Not found")
+			return Cache{}, "", newOpError(rf.logger, "get", key, TierMemory, ErrNotFound)
 		}
 
 		// * Check if the item is valid
-		return item.Data, nil
+		rf.metrics.hit(TierMemory)
+		return item, TierMemory, nil
 	}
 
-	return rf.loadFromFile(key)
+	if ctx.Err() != nil {
+		return Cache{}, "", ctxError("get", key, TierFile, ctx.Err())
+	}
+	item, err := rf.loadFromFile(key)
+	return item, TierFile, err
 }
 
-func (rf *RedisFallback) loadFromFile(key string) (interface{}, error) {
+func (rf *RedisFallback) loadFromFile(key string) (Cache, error) {
+	item, err := rf.readFile(key)
+	if err != nil {
+		return Cache{}, err
+	}
+
+	// * Update memory cache, only hot keys when PromoteAfter is set
+	if rf.shouldPromote(key) {
+		rf.storeCache(key, item)
+	}
+
+	rf.metrics.hit(TierFile)
+	return item, nil
+}
+
+func (rf *RedisFallback) readFile(key string) (Cache, error) {
+	// * Deleted while Redis was down, the file may still hold the old value
+	if _, ok := rf.tombstones.Load(key); ok {
+		return Cache{}, newOpError(rf.logger, "get", key, TierFile, ErrNotFound)
+	}
+	// * Key was never written to disk
+	if !rf.bloom.hasKey(key) {
+		return Cache{}, newOpError(rf.logger, "get", key, TierFile, ErrNotFound)
+	}
+
 	path := getPath(rf.config, key)
 
-	// * Check if the file exists
-	data, err := os.ReadFile(path.filepath)
-	if err != nil {
-		return nil, rf.logger.Error(nil, "Not found")
+	// * Segment holds the newest flushed value until it is checkpointed
+	data, ok, err := rf.writer.readSegment(key)
+	if !ok {
+		// * Check if the file exists
+		data, err = os.ReadFile(path.filepath)
+	}
+	if os.IsNotExist(err) {
+		return Cache{}, newOpError(rf.logger, "get", key, TierFile, ErrNotFound)
+	} else if err != nil {
+		return Cache{}, newOpError(rf.logger, "get", key, TierFile, err)
 	}
 
-	var item Cache
 	// * Parse the JSON data
-	if err := json.Unmarshal(data, &item); err != nil {
-		return nil, rf.logger.Error(nil, "Failed to parse")
+	item, err := decodeCache(rf.config, rf.marshalers, data)
+	if err != nil {
+		return Cache{}, newOpError(rf.logger, "get", key, TierFile, parseError(err))
+	}
+
+	// * Deleted while Redis was down, kept until recovery
+	if item.Type == tombstoneType {
+		return Cache{}, newOpError(rf.logger, "get", key, TierFile, ErrNotFound)
 	}
 
 	// * Check if the item is expired
-	if isExpired(item) {
+	if isExpired(item, rf.now()) {
 		rf.removeJSONFile(key)
 
-		return nil, rf.logger.Error(nil, "Not found")
+		return Cache{}, newOpError(rf.logger, "get", key, TierFile, ErrNotFound)
 	}
 
-	// * Update memory cache
-	rf.cache.Store(key, item)
-
-	return item.Data, nil
+	return item, nil
 }
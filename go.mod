@@ -2,10 +2,12 @@ module github.com/pardnchiu/go-redis-fallback
 
 go 1.24.3
 
-require github.com/redis/go-redis/v9 v9.10.0
+require (
+	github.com/pardnchiu/go-logger v0.2.0
+	github.com/redis/go-redis/v9 v9.10.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/pardnchiu/go-logger v0.2.0 // indirect
 )
@@ -0,0 +1,398 @@
+package redisFallback
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const hashType = "hash" // 以 HSetField 寫入的本地文件型別
+
+type hashes struct {
+	mutex sync.Mutex
+}
+
+// * 設定雜湊的單一欄位，降級時只更新本地文件中的該欄位，不需整份取代
+func (rf *RedisFallback) HSetField(key string, field string, value interface{}) error {
+	if err := rf.validateKey("hset", key); err != nil {
+		return err
+	}
+	if err := rf.checkWritable("hset", key); err != nil {
+		return err
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		data, err := rf.config.Options.Encoder.Marshal(value)
+		if err != nil {
+			return newOpError(rf.logger, "hset", key, TierRedis, parseError(err))
+		}
+
+		ctx := context.Background()
+		for i := 0; rf.canRetry(i); i++ {
+			if err = rf.redis.HSet(ctx, key, field, string(data)).Err(); err == nil {
+				if !rf.config.Options.DisableMirror {
+					rf.updateHash(key, map[string]interface{}{field: value}, nil, false)
+				}
+				return nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("hset", key, err); err != nil {
+			return err
+		}
+	}
+
+	_, err := rf.updateHash(key, map[string]interface{}{field: value}, nil, true)
+	return err
+}
+
+// * 取得雜湊的單一欄位
+func (rf *RedisFallback) HGetField(key string, field string) (interface{}, error) {
+	if err := rf.validateKey("hget", key); err != nil {
+		return nil, err
+	}
+
+	rf.metrics.gets.Add(1)
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var result string
+			result, err = rf.redis.HGet(ctx, key, field).Result()
+			if err == redis.Nil {
+				rf.metrics.misses.Add(1)
+				return nil, newOpError(rf.logger, "hget", key, TierRedis, ErrNotFound)
+			}
+			if err == nil {
+				var value interface{}
+				if err := rf.config.Options.Encoder.Unmarshal([]byte(result), &value); err != nil {
+					return nil, newOpError(rf.logger, "hget", key, TierRedis, parseError(err))
+				}
+				rf.metrics.hit(TierRedis)
+				return value, nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("hget", key, err); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, err := rf.localHash("hget", key)
+	if err != nil {
+		rf.metrics.misses.Add(1)
+		return nil, err
+	}
+	data, ok := fields[field]
+	if !ok {
+		rf.metrics.misses.Add(1)
+		return nil, newOpError(rf.logger, "hget", key, TierMemory, ErrNotFound)
+	}
+	return data, nil
+}
+
+// * 一次設定多個欄位，Redis 以單一 HSET 寫入
+func (rf *RedisFallback) HSet(key string, fields map[string]interface{}) error {
+	if err := rf.validateKey("hset", key); err != nil {
+		return err
+	}
+	if err := rf.checkWritable("hset", key); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return newOpError(rf.logger, "hset", key, TierMemory, fmt.Errorf("%w: empty hash", ErrType))
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		args, err := rf.hashArgs(Cache{Data: fields})
+		if err != nil {
+			return newOpError(rf.logger, "hset", key, TierRedis, parseError(err))
+		}
+
+		ctx := context.Background()
+		for i := 0; rf.canRetry(i); i++ {
+			if err = rf.redis.HSet(ctx, key, args...).Err(); err == nil {
+				if !rf.config.Options.DisableMirror {
+					rf.updateHash(key, fields, nil, false)
+				}
+				return nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("hset", key, err); err != nil {
+			return err
+		}
+	}
+
+	_, err := rf.updateHash(key, fields, nil, true)
+	return err
+}
+
+// * 同 HGetField
+func (rf *RedisFallback) HGet(key string, field string) (interface{}, error) {
+	return rf.HGetField(key, field)
+}
+
+// * 取得雜湊的所有欄位，回傳的 map 為複本
+func (rf *RedisFallback) HGetAll(key string) (map[string]interface{}, error) {
+	if err := rf.validateKey("hgetall", key); err != nil {
+		return nil, err
+	}
+
+	rf.metrics.gets.Add(1)
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var result map[string]string
+			result, err = rf.redis.HGetAll(ctx, key).Result()
+			if rf.stopRetry(err) {
+				break
+			}
+			if err != nil {
+				continue
+			}
+			// * HGETALL returns an empty map for a missing key
+			if len(result) == 0 {
+				rf.metrics.misses.Add(1)
+				return nil, newOpError(rf.logger, "hgetall", key, TierRedis, ErrNotFound)
+			}
+
+			fields := make(map[string]interface{}, len(result))
+			for field, data := range result {
+				var value interface{}
+				if err := rf.config.Options.Encoder.Unmarshal([]byte(data), &value); err != nil {
+					return nil, newOpError(rf.logger, "hgetall", key, TierRedis, parseError(err))
+				}
+				fields[field] = value
+			}
+			rf.metrics.hit(TierRedis)
+			return fields, nil
+		}
+
+		if err := rf.redisFailed("hgetall", key, err); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, err := rf.localHash("hgetall", key)
+	if err != nil {
+		rf.metrics.misses.Add(1)
+		return nil, err
+	}
+	return fields, nil
+}
+
+// * 刪除雜湊的欄位，回傳實際刪除的數量；降級時記錄刪除的欄位，復原時以 HDEL 同步
+func (rf *RedisFallback) HDel(key string, fields ...string) (int, error) {
+	if err := rf.validateKey("hdel", key); err != nil {
+		return 0, err
+	}
+	if err := rf.checkWritable("hdel", key); err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var count int64
+			count, err = rf.redis.HDel(ctx, key, fields...).Result()
+			if err == nil {
+				if !rf.config.Options.DisableMirror {
+					rf.updateHash(key, nil, fields, false)
+				}
+				return int(count), nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("hdel", key, err); err != nil {
+			return 0, err
+		}
+	}
+
+	return rf.updateHash(key, nil, fields, true)
+}
+
+// * 本地雜湊的複本，空雜湊視為不存在
+func (rf *RedisFallback) localHash(op string, key string) (map[string]interface{}, error) {
+	rf.hashes.mutex.Lock()
+	item, ok := rf.localItem(key)
+	rf.hashes.mutex.Unlock()
+	if !ok {
+		return nil, newOpError(rf.logger, op, key, TierMemory, ErrNotFound)
+	}
+	list, ok := item.Data.(map[string]interface{})
+	if !ok {
+		return nil, newOpError(rf.logger, op, key, TierMemory, fmt.Errorf("%w: %T is not a hash", ErrType, item.Data))
+	}
+	if len(list) == 0 {
+		return nil, newOpError(rf.logger, op, key, TierMemory, ErrNotFound)
+	}
+
+	fields := make(map[string]interface{}, len(list))
+	for field, value := range list {
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// * 複製後設定與刪除欄位，保留原本的到期時間，回傳實際刪除的欄位數
+// * persist 為 true 時寫入本地檔案，並記錄刪除的欄位供復原時 HDEL
+func (rf *RedisFallback) updateHash(key string, set map[string]interface{}, del []string, persist bool) (int, error) {
+	rf.hashes.mutex.Lock()
+	defer rf.hashes.mutex.Unlock()
+
+	now := rf.now()
+	item := Cache{Key: key, Type: hashType, Timestamp: now.Unix()}
+	fields := make(map[string]interface{})
+	removed := make(map[string]struct{})
+
+	if old, ok := rf.localItem(key); ok && old.Type == hashType {
+		if old.TTL > 0 {
+			item.TTL = max(old.Timestamp+old.TTL-item.Timestamp, 1)
+		}
+		if list, ok := old.Data.(map[string]interface{}); ok {
+			for k, v := range list {
+				fields[k] = v
+			}
+		}
+		// * Redis already applied them while healthy, unless recovery has not synced them yet
+		if persist || rf.isDirty(key) {
+			for _, field := range old.Removed {
+				removed[field] = struct{}{}
+			}
+		}
+	}
+
+	for field, value := range set {
+		fields[field] = value
+		delete(removed, field)
+	}
+	count := 0
+	for _, field := range del {
+		if _, ok := fields[field]; ok {
+			delete(fields, field)
+			count++
+		}
+		if persist {
+			removed[field] = struct{}{}
+		}
+	}
+
+	item.Data = fields
+	for field := range removed {
+		item.Removed = append(item.Removed, field)
+	}
+
+	if !persist {
+		// * Nothing left to mirror, Redis dropped the key with its last field
+		if len(fields) == 0 {
+			rf.deleteCache(key)
+			return count, nil
+		}
+		rf.storeCache(key, item)
+		return count, nil
+	}
+	return count, rf.setToMemory(context.Background(), key, item, PriorityNormal)
+}
+
+// * 記憶體層優先，其次本地檔案
+func (rf *RedisFallback) localItem(key string) (Cache, bool) {
+	if cached, ok := rf.cache.Load(key); ok && !isExpired(cached.(Cache), rf.now()) {
+		return cached.(Cache), true
+	}
+	item, err := rf.readFile(key)
+	return item, err == nil
+}
+
+// * 雜湊以 HDEL 與 HSET 寫入（與 Redis 既有欄位合併），集合以 SREM 與 SADD 寫入（與 Redis 既有成員聯集），有序集合依 ZSetMerge 以 ZADD 寫入，計數器以 INCRBY 補回差值，清單以 LPUSH / RPUSH 補回，訊息以 PUBLISH 補發，刪除紀錄以 DEL，其餘以 SET 寫入 Cache 封裝
+func (rf *RedisFallback) writeItem(ctx context.Context, c redis.Cmdable, key string, item Cache) (redis.Cmder, error) {
+	var cmd redis.Cmder
+	switch item.Type {
+	case hashType:
+		if len(item.Removed) > 0 {
+			cmd = c.HDel(ctx, key, item.Removed...)
+		}
+		// * Every field removed offline, HDEL alone is enough
+		if fields, _ := item.Data.(map[string]interface{}); len(fields) > 0 || cmd == nil {
+			args, err := rf.hashArgs(item)
+			if err != nil {
+				return nil, err
+			}
+			cmd = c.HSet(ctx, key, args...)
+		}
+	case setType:
+		if len(item.Removed) > 0 {
+			cmd = c.SRem(ctx, key, memberArgs(item.Removed)...)
+		}
+		// * Union with the members already in Redis
+		if set := setMembers(item); len(set) > 0 {
+			cmd = c.SAdd(ctx, key, memberArgs(setList(set))...)
+		}
+		if cmd == nil {
+			return nil, fmt.Errorf("%w: empty set", ErrType)
+		}
+	case zsetType:
+		zcmd, err := rf.writeZSet(ctx, c, key, item)
+		if err != nil {
+			return nil, err
+		}
+		cmd = zcmd
+	case counterType:
+		cmd = c.IncrBy(ctx, key, item.Delta)
+	case tombstoneType:
+		return c.Del(ctx, key), nil
+	case listType:
+		return rf.writeList(ctx, c, key, item)
+	default:
+		data, err := rf.marshalCache(item)
+		if err != nil {
+			return nil, err
+		}
+		return c.SetArgs(ctx, key, data, setArgs(item)), nil
+	}
+
+	if item.TTL > 0 {
+		c.ExpireAt(ctx, key, time.Unix(item.Timestamp+item.TTL, 0))
+	}
+	return cmd, nil
+}
+
+// * 欄位與以 Encoder 序列化後的值交錯排列
+func (rf *RedisFallback) hashArgs(item Cache) ([]interface{}, error) {
+	fields, ok := item.Data.(map[string]interface{})
+	if !ok || len(fields) == 0 {
+		return nil, fmt.Errorf("%w: empty or invalid hash", ErrType)
+	}
+
+	args := make([]interface{}, 0, len(fields)*2)
+	for field, value := range fields {
+		data, err := rf.config.Options.Encoder.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, field, string(data))
+	}
+	return args, nil
+}
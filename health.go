@@ -0,0 +1,58 @@
+package redisFallback
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type HealthStatus struct {
+	Mode         string `json:"mode"`
+	IsRecovering bool   `json:"is_recovering"`
+	Backlog      int    `json:"backlog"`
+}
+
+func (rf *RedisFallback) healthStatus() HealthStatus {
+	rf.mutex.RLock()
+	mode := modeName(rf.isHealth)
+	rf.mutex.RUnlock()
+
+	return HealthStatus{
+		Mode:         mode,
+		IsRecovering: rf.isRecovering.Load(),
+		Backlog:      rf.backlog(),
+	}
+}
+
+// * 待寫入檔案的資料筆數
+func (rf *RedisFallback) backlog() int {
+	rf.writer.mutex.Lock()
+	pending := len(rf.writer.pending)
+	rf.writer.mutex.Unlock()
+
+	return pending
+}
+
+// * 存活檢查：程序運作中即回傳 200，降級時同樣視為存活
+func (rf *RedisFallback) HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, rf.healthStatus())
+	})
+}
+
+// * 就緒檢查：正常模式且未在復原中回傳 200，否則 503
+func (rf *RedisFallback) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := rf.healthStatus()
+		code := http.StatusOK
+		if status.Mode != modeName(true) || status.IsRecovering {
+			code = http.StatusServiceUnavailable
+		}
+		writeHealth(w, code, status)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, code int, status HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
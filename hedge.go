@@ -0,0 +1,60 @@
+package redisFallback
+
+import (
+	"context"
+)
+
+type hedgeResult struct {
+	item Cache
+	tier string
+	ok   bool
+}
+
+// * 同時查詢 Redis 與本地檔案，回傳最先取得的有效結果
+func (rf *RedisFallback) getHedged(ctx context.Context, key string) (Cache, string, error) {
+	if cached, ok := rf.cache.Load(key); ok && !isExpired(cached.(Cache), rf.now()) {
+		rf.metrics.hit(TierMemory)
+		return cached.(Cache), TierMemory, nil
+	}
+
+	ch := make(chan hedgeResult, 2)
+
+	go func() {
+		result, pttl, err := rf.getCoalesced(ctx, key)
+		if err != nil {
+			ch <- hedgeResult{}
+			return
+		}
+		item, ok := rf.parseRedisValue(key, result)
+		if !ok {
+			ch <- hedgeResult{}
+			return
+		}
+		item = applyRemainingTTL(item, pttl, rf.now())
+		if isExpired(item, rf.now()) {
+			ch <- hedgeResult{}
+			return
+		}
+		rf.repairLocal(key, item)
+		rf.metrics.hit(TierRedis)
+		ch <- hedgeResult{item: item, tier: TierRedis, ok: true}
+	}()
+
+	go func() {
+		item, err := rf.loadFromFile(key)
+		ch <- hedgeResult{item: item, tier: TierFile, ok: err == nil}
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ctx.Done():
+			return Cache{}, "", ctxError("get", key, TierRedis, ctx.Err())
+		case result := <-ch:
+			if result.ok {
+				return result.item, result.tier, nil
+			}
+		}
+	}
+
+	return Cache{}, "", newOpError(rf.logger, "get", key, TierRedis, ErrNotFound)
+}
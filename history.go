@@ -0,0 +1,43 @@
+package redisFallback
+
+import (
+	"sync"
+)
+
+const maxTransitions = 20 // 狀態文件保留的最近模式切換數
+
+type Transition struct {
+	Time  int64  `json:"time"`
+	Mode  string `json:"mode"`
+	Cause string `json:"cause"`
+}
+
+// * 最近的模式切換紀錄，供排查連線反覆中斷使用
+type transitions struct {
+	mutex sync.Mutex
+	list  []Transition
+}
+
+func (rf *RedisFallback) recordTransition(mode string, cause string) {
+	rf.transitions.mutex.Lock()
+	defer rf.transitions.mutex.Unlock()
+
+	rf.transitions.list = append(rf.transitions.list, Transition{Time: rf.now().Unix(), Mode: mode, Cause: cause})
+	if len(rf.transitions.list) > maxTransitions {
+		rf.transitions.list = rf.transitions.list[len(rf.transitions.list)-maxTransitions:]
+	}
+}
+
+func (rf *RedisFallback) transitionHistory() []Transition {
+	rf.transitions.mutex.Lock()
+	defer rf.transitions.mutex.Unlock()
+
+	list := make([]Transition, len(rf.transitions.list))
+	copy(list, rf.transitions.list)
+	return list
+}
+
+// * 最近一次健康檢查成功的時間
+func (rf *RedisFallback) heartbeat() {
+	rf.lastPing.Store(rf.now().Unix())
+}
@@ -0,0 +1,65 @@
+package redisFallback
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// * 測試與混沌工具使用的注入點，可延遲或回傳錯誤以重現降級與恢復流程
+type Hook interface {
+	BeforeRedisOp(ctx context.Context, op string) error           // 回傳錯誤時略過該 Redis 操作
+	AfterRedisOp(ctx context.Context, op string, err error) error // 回傳值取代原本的結果
+	BeforeFileWrite(key string) error                             // 回傳錯誤時略過該檔案寫入
+}
+
+// * 將 Hook 接到 go-redis 的指令流程
+type redisHook struct {
+	hook Hook
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := h.hook.BeforeRedisOp(ctx, cmd.Name()); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err := h.hook.AfterRedisOp(ctx, cmd.Name(), next(ctx, cmd))
+		if err != nil {
+			cmd.SetErr(err)
+		}
+		return err
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := h.hook.BeforeRedisOp(ctx, "pipeline"); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		result := next(ctx, cmds)
+		err := h.hook.AfterRedisOp(ctx, "pipeline", result)
+		// * Callers read cmd.Err(), keep each command's own error when the result is passed through
+		if err != nil && !errors.Is(err, result) {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+		}
+		return err
+	}
+}
+
+func (w *Writer) beforeFileWrite(key string) error {
+	if w.config.Options.Hook == nil {
+		return nil
+	}
+	return w.config.Options.Hook.BeforeFileWrite(key)
+}
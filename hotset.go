@@ -0,0 +1,157 @@
+package redisFallback
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultHotSetInterval = 1 * time.Minute // 記錄熱門金鑰的間隔
+
+// * 近似 LRU：只記錄每個金鑰最後一次讀取的時間，儲存時取最近讀取的 HotSet 筆
+type hotSet struct {
+	access sync.Map // key -> 最後讀取時間（UnixNano）
+	size   atomic.Int64
+}
+
+// * 記錄讀取成功的金鑰，追蹤數量超過 HotSet 的 4 倍時不再加入新金鑰，直到下次儲存
+func (rf *RedisFallback) touchHot(key string) {
+	limit := int64(rf.config.Options.HotSet)
+	if limit <= 0 {
+		return
+	}
+
+	now := rf.now().UnixNano()
+	if _, ok := rf.hot.access.Load(key); ok {
+		rf.hot.access.Store(key, now)
+		return
+	}
+	if rf.hot.size.Load() >= limit*4 {
+		return
+	}
+	if _, loaded := rf.hot.access.LoadOrStore(key, now); !loaded {
+		rf.hot.size.Add(1)
+	}
+}
+
+// * 定期將最近讀取的金鑰寫入 {DBPath}/{db}.hot，啟動時依序載入記憶體層
+func (rf *RedisFallback) startHotSet() {
+	if rf.config.Options.HotSet <= 0 {
+		return
+	}
+
+	rf.goroutine(rf.loadHotSet)
+
+	ticker := rf.config.Options.Clock.NewTicker(defaultHotSetInterval)
+	rf.goroutine(func() {
+		for {
+			select {
+			case <-rf.closed:
+				ticker.Stop()
+				return
+			case <-ticker.C():
+				if err := rf.saveHotSet(); err != nil {
+					rf.logger.Error(err, "Failed to save hot set")
+				}
+			}
+		}
+	})
+}
+
+// * 最近讀取的在前，只保留前 HotSet 筆繼續追蹤
+func (rf *RedisFallback) saveHotSet() error {
+	type entry struct {
+		key    string
+		access int64
+	}
+	var list []entry
+	rf.hot.access.Range(func(key, value interface{}) bool {
+		list = append(list, entry{key: key.(string), access: value.(int64)})
+		return true
+	})
+	if len(list) == 0 {
+		return nil
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].access > list[j].access
+	})
+	limit := rf.config.Options.HotSet
+	for _, e := range list[min(limit, len(list)):] {
+		rf.hot.access.Delete(e.key)
+		rf.hot.size.Add(-1)
+	}
+	list = list[:min(limit, len(list))]
+
+	var sb strings.Builder
+	for _, e := range list {
+		sb.WriteString(strconv.Quote(e.key))
+		sb.WriteByte('\n')
+	}
+
+	path := hotSetPath(rf.config)
+	if err := os.MkdirAll(filepath.Dir(path), rf.config.Options.DirMode); err != nil {
+		return err
+	}
+	// * Rename so a crash never leaves a half written list
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), rf.config.Options.FileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// * 依記錄的順序載入記憶體層，Redis 正常時從 Redis 讀取，降級時從本地檔案讀取
+func (rf *RedisFallback) loadHotSet() {
+	file, err := os.Open(hotSetPath(rf.config))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			rf.logger.Error(err, "Failed to open hot set")
+		}
+		return
+	}
+	defer file.Close()
+
+	ctx := context.Background()
+	loaded := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && loaded < rf.config.Options.HotSet {
+		select {
+		case <-rf.closed:
+			return
+		default:
+		}
+
+		key, err := strconv.Unquote(scanner.Text())
+		if err != nil {
+			continue
+		}
+		// * Set after startup, already fresher than the saved copy
+		if _, ok := rf.cache.Load(key); ok {
+			continue
+		}
+
+		if rf.isHealthy() {
+			if _, _, err := rf.getFromRedis(ctx, key); err == nil {
+				loaded++
+			}
+			continue
+		}
+		if item, err := rf.readFile(key); err == nil && rf.storeCache(key, item) {
+			loaded++
+		}
+	}
+
+	rf.logger.Info("Loaded hot set", loaded)
+}
+
+func hotSetPath(config Config) string {
+	return filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB)+".hot")
+}
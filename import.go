@@ -0,0 +1,100 @@
+package redisFallback
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// * redis-dump 輸出的單行格式
+type dumpEntry struct {
+	DB    int             `json:"db"`
+	Key   string          `json:"key"`
+	TTL   int64           `json:"ttl"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// * 從 RDB 檔匯入目前 DB 的字串金鑰至本地檔案，回傳匯入筆數
+func (rf *RedisFallback) ImportRDB(r io.Reader) (int, error) {
+	count := 0
+	err := readRDB(r, func(entry rdbEntry) error {
+		if entry.db != rf.config.Redis.DB {
+			return nil
+		}
+
+		ttl := time.Duration(-1)
+		if entry.expireAt > 0 {
+			ttl = time.UnixMilli(entry.expireAt).Sub(rf.now())
+			// * Already expired
+			if ttl <= 0 {
+				return nil
+			}
+		}
+
+		if rf.importValue(entry.key, entry.value, ttl) == nil {
+			count++
+		}
+		return nil
+	}, func(entry rdbEntry) {
+		if entry.db == rf.config.Redis.DB {
+			rf.logger.Info("[ImportRDB] Skipped unsupported value", "key", rf.logger.key(entry.key), "type", entry.valueType)
+		}
+	})
+	return count, err
+}
+
+// * 從 redis-dump JSON 匯入目前 DB 的字串金鑰至本地檔案，回傳匯入筆數
+func (rf *RedisFallback) ImportJSON(r io.Reader) (int, error) {
+	count := 0
+	decoder := json.NewDecoder(r)
+	for {
+		var entry dumpEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, parseError(err)
+		}
+
+		if entry.DB != rf.config.Redis.DB || entry.Type != "string" {
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(entry.Value, &value); err != nil {
+			continue
+		}
+
+		// * -1: no expiration
+		ttl := time.Duration(-1)
+		if entry.TTL >= 0 {
+			ttl = time.Duration(entry.TTL) * time.Second
+		}
+
+		if rf.importValue(entry.Key, value, ttl) == nil {
+			count++
+		}
+	}
+}
+
+// * 本套件寫入的值保留原本的 Cache 封裝，其餘視為字串；ttl 為 -1 代表不過期
+func (rf *RedisFallback) importValue(key string, value string, ttl time.Duration) error {
+	if err := rf.validateKey("import", key); err != nil {
+		return err
+	}
+
+	now := rf.now()
+	item, err := decodeCache(rf.config, rf.marshalers, []byte(value))
+	if err != nil || item.Key != key {
+		item = Cache{
+			Key:       key,
+			Data:      value,
+			Type:      "string",
+			Timestamp: now.Unix(),
+		}
+	}
+
+	return rf.writer.writeToFile(key, applyRemainingTTL(item, ttl, now))
+}
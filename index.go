@@ -0,0 +1,60 @@
+package redisFallback
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// * 本地檔案的金鑰索引，避免以 MD5 路徑反查金鑰
+type keyIndex struct {
+	keys sync.Map
+	once sync.Once
+}
+
+func (i *keyIndex) add(key string) {
+	i.keys.Store(key, struct{}{})
+}
+
+func (i *keyIndex) remove(key string) {
+	i.keys.Delete(key)
+}
+
+func (i *keyIndex) clear() {
+	i.keys.Clear()
+}
+
+// * 第一次使用時讀取既有檔案建立索引
+func (i *keyIndex) load(config Config, m *marshalers) {
+	i.once.Do(func() {
+		folderPath := filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB))
+
+		filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			if item, err := decodeCache(config, m, data); err == nil && item.Type != tombstoneType {
+				i.add(item.Key)
+			}
+			return nil
+		})
+	})
+}
+
+func (i *keyIndex) match(prefix string) []string {
+	var list []string
+	i.keys.Range(func(key, value interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			list = append(list, key.(string))
+		}
+		return true
+	})
+	return list
+}
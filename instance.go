@@ -3,8 +3,8 @@ package redisFallback
 import (
 	"context"
 	"fmt"
-	"net/smtp"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -13,50 +13,171 @@ import (
 )
 
 func New(c Config) (*RedisFallback, error) {
+	return newInstance(c, nil)
+}
+
+// * shared 不為 nil 時由 Multi 統一健康檢查與排程寫入，並共用日誌、檔案寫入數與副本佇列
+func newInstance(c Config, shared *shared) (*RedisFallback, error) {
+	c, err := resolveOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	c = applyName(c)
 	c.Log = validLoggerConfig(c)
-	c.Option = validOptionData(c)
+	c.Options = validOptionData(c)
+	if c.Options.Compression != "" {
+		if _, err := getCompressor(c.Options.Compression); err != nil {
+			return nil, err
+		}
+	}
 
-	logger, err := goLogger.New(c.Log)
+	logger, err := shared.getLogger(c)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to initialize `pardnchiu/go-logger`: %w", err)
+		return nil, err
 	}
 
 	// * Initialize Redis
-	redisClient := initRedis(c)
+	var redisClient redis.UniversalClient
+	creds := &credentials{}
+	nodes := &nodeHealth{}
+	if c.Ring != nil {
+		// * Local folder follows the ring DB
+		c.Redis = &Redis{DB: c.Ring.DB}
+		creds.username, creds.password = c.Ring.Username, c.Ring.Password
+		redisClient = initRing(c, creds, nodes)
+	} else {
+		if c.Redis == nil {
+			c.Redis = &Redis{}
+		}
+		creds.username, creds.password = c.Redis.Username, c.Redis.Password
+		redisClient = initRedis(c, creds, shared.poolSize())
+	}
+	if c.Options.Hook != nil {
+		redisClient.AddHook(redisHook{hook: c.Options.Hook})
+	}
+	migrator := newMigration(c, logger)
+	if migrator != nil {
+		redisClient.AddHook(migrator)
+	}
+
+	// * Segments left by the last run become key files before anything reads the folder
+	if _, err := checkpointSegments(c); err != nil {
+		logger.Error(err, "Failed to checkpoint segments")
+	}
+
+	// * Initialize bloom filter from existing fallback files
+	bloom := newBloomFilter(defaultBloomBits, defaultBloomHashes)
+	bloom.load(c)
+
+	marshalers := &marshalers{}
+	marshalers.registerTime(c.Options.TimeFormat)
+	index := &keyIndex{}
+
+	auditor, err := newAuditor(c.Options)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open audit file: %w", err)
+	}
 
 	ctx := context.Background()
 	redisFallback := &RedisFallback{
-		config:  c,
-		logger:  logger,
-		redis:   redisClient,
-		context: ctx,
+		config:        c,
+		logger:        logger,
+		redis:         redisClient,
+		context:       ctx,
+		bloom:         bloom,
+		marshalers:    marshalers,
+		index:         index,
+		auditor:       auditor,
+		closed:        make(chan struct{}),
+		sharedChecker: shared != nil,
+		credentials:   creds,
+		nodes:         nodes,
+		migration:     migrator,
+		namespaces:    newNamespaces(c.Options.Namespaces, c.Options.Quotas),
+		retryBudget:   newRetryBudget(c.Options.RetryBudget, c.Options.Clock),
 		writer: &Writer{
-			config:  c,
-			logger:  logger,
-			queue:   make(chan WriteRequest, c.Option.MaxQueue),
-			timer:   time.NewTicker(c.Option.TimeToWrite),
-			pending: make(map[string]interface{}),
+			config:     c,
+			logger:     logger,
+			bloom:      bloom,
+			marshalers: marshalers,
+			index:      index,
+			pending:    make(map[string]WriteRequest),
+			written:    make(map[string]time.Time),
+			slots:      shared.getSlots(c),
+			kick:       shared.getKick(),
+			segments:   newSegments(c),
 		},
 	}
 
+	redisFallback.writer.onDiskDown = redisFallback.changeToMemoryOnly
+	redisFallback.detector = newDetector(redisFallback)
+	if redisFallback.detector != nil {
+		redisClient.AddHook(redisFallback.detector)
+	}
+	// * Built with nofallbackdisk, fallback mode keeps values in memory only
+	if !fallbackDisk {
+		redisFallback.writer.diskDown.Store(true)
+	}
+	if c.Options.ReplicaPath != "" && fallbackDisk {
+		if shared != nil {
+			redisFallback.writer.replica = shared.getReplica()
+		} else {
+			redisFallback.writer.replica = newReplicaQueue()
+			redisFallback.goroutine(func() {
+				redisFallback.writer.startReplica(redisFallback.closed)
+			})
+		}
+	}
+
+	// * Messages left by the last run, replayed on the first recovery
+	if fallbackDisk {
+		redisFallback.loadMessages()
+	}
+
 	// * check Redis connection
-	if err := redisClient.Ping(ctx).Err(); err != nil {
+	if err := redisFallback.checkHealthy(ctx); err != nil {
 		// * fallback mode
 		logger.Error(err, "Failed to connect, Starting fallback mode")
-		redisFallback.changeToFallbackMode()
+		redisFallback.changeToFallbackMode("startup: " + err.Error())
+		redisFallback.preloadFromFile()
 	} else {
 		// * normal mode
 		logger.Info("Starting normal mode")
-		redisFallback.changeToNormalMode()
+		redisFallback.changeToNormalMode("startup")
 	}
 
-	go redisFallback.writer.start()
-	go redisFallback.startMemoryCleanup()
+	if fallbackDisk {
+		if shared == nil {
+			redisFallback.writer.timer = c.Options.Clock.NewTicker(c.Options.TimeToWrite)
+			redisFallback.goroutine(redisFallback.writer.start)
+		}
+		redisFallback.startCompaction()
+		redisFallback.startPrune()
+	}
+	redisFallback.startMemoryCleanup()
+	redisFallback.startStatsD()
+	redisFallback.startTTLSync()
+	redisFallback.startHotSet()
 
 	return redisFallback, nil
 }
 
-func initRedis(c Config) *redis.Client {
+// * 套用 Label 與 KeyRedaction，Multi 的各 DB 共用同一個
+func newLogger(c Config) (*logger, error) {
+	baseLogger, err := goLogger.New(c.Log)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize `pardnchiu/go-logger`: %w", err)
+	}
+	return &logger{
+		Logger:    baseLogger,
+		clock:     c.Options.Clock,
+		label:     c.Options.Label,
+		redaction: c.Options.KeyRedaction,
+	}, nil
+}
+
+// * poolSize 為 0 時使用 go-redis 預設的連線數
+func initRedis(c Config, creds *credentials, poolSize int) *redis.Client {
 	if c.Redis == nil {
 		c.Redis = &Redis{
 			Host:     "localhost",
@@ -76,9 +197,11 @@ func initRedis(c Config) *redis.Client {
 	}
 
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", c.Redis.Host, c.Redis.Port),
-		Password: c.Redis.Password,
-		DB:       c.Redis.DB,
+		Addr: fmt.Sprintf("%s:%d", c.Redis.Host, c.Redis.Port),
+		DB:   c.Redis.DB,
+		// * Read on every new connection so rotated credentials apply without a restart
+		CredentialsProvider: creds.get,
+		PoolSize:            poolSize,
 	})
 	return redisClient
 }
@@ -86,49 +209,90 @@ func initRedis(c Config) *redis.Client {
 func (rf *RedisFallback) removeJSONFile(key string) {
 	path := getPath(rf.config, key)
 	os.Remove(path.filepath)
+	rf.writer.dropSegment(key)
+	rf.writer.replicaRemove(path.filepath)
+	rf.index.remove(key)
 }
 
 func (rf *RedisFallback) Close() {
+	select {
+	case <-rf.closed:
+		return
+	default:
+		close(rf.closed)
+	}
+	rf.mutex.Lock()
 	if rf.checker != nil {
 		rf.checker.Stop()
 	}
-	rf.writer.timer.Stop()
+	rf.mutex.Unlock()
+	// * Keep the reads since the last tick for the next start
+	if rf.config.Options.HotSet > 0 {
+		if err := rf.saveHotSet(); err != nil {
+			rf.logger.Error(err, "Failed to save hot set")
+		}
+	}
+	if rf.writer.timer != nil {
+		rf.writer.timer.Stop()
+	}
+	rf.closePubSub()
 	rf.redis.Close()
+	if rf.migration != nil {
+		rf.migration.target.Close()
+	}
+	rf.auditor.close()
 }
 
-func (m *RedisFallback) sendEmail(ip string, reason string) {
-	if m.config.Email == nil {
-		return
+// * Email 通知使用的 Redis 位址，Ring 以逗號串接各節點
+func (rf *RedisFallback) redisAddress() string {
+	if rf.config.Ring != nil {
+		var list []string
+		for _, addr := range rf.config.Ring.Addrs {
+			list = append(list, addr)
+		}
+		return strings.Join(list, ",")
 	}
+	return fmt.Sprintf("%s:%d", rf.config.Redis.Host, rf.config.Redis.Port)
+}
 
-	subject := fmt.Sprintf("[IP Sentry] IP %s has been banned", ip)
-	if m.config.Email.Subject != nil {
-		str := (*m.config.Email.Subject)(ip, reason)
-		if str != "" {
-			subject = str
-		}
+// * Option 為舊名稱，對應至 Options；兩者同時設定且不同時回傳錯誤
+func resolveOptions(c Config) (Config, error) {
+	if c.Option == nil {
+		return c, nil
 	}
-	body := fmt.Sprintf("[IP Sentry] IP %s has been banned for %s", ip, reason)
-	if m.config.Email.Body != nil {
-		str := (*m.config.Email.Body)(ip, reason)
-		if str != "" {
-			body = str
-		}
+	if c.Options != nil && c.Options != c.Option {
+		return c, fmt.Errorf("Config.Option is deprecated and conflicts with Config.Options, set only Config.Options")
 	}
-	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nCc: %s\r\nSubject: %s\r\n\r\n%s",
-		m.config.Email.From,
-		strings.Join(m.config.Email.To, ","),
-		strings.Join(m.config.Email.CC, ","),
-		subject,
-		body)
+	c.Options = c.Option
+	c.Option = nil
+	return c, nil
+}
 
-	auth := smtp.PlainAuth("", m.config.Email.Username, m.config.Email.Password, m.config.Email.Host)
-	addr := fmt.Sprintf("%s:%d", m.config.Email.Host, m.config.Email.Port)
+func applyName(c Config) Config {
+	if c.Name == "" {
+		return c
+	}
+	return withName(c, c.Name)
+}
 
-	err := smtp.SendMail(addr, auth, m.config.Email.From, m.config.Email.To, []byte(msg))
-	if err != nil {
-		m.logger.Error(err, "Failed to send email")
+// * 以名稱區分本地目錄與日誌前綴，複製 Options 避免影響共用同一份設定的其他實例；Name 與 NewSharded 的分片共用
+func withName(c Config, name string) Config {
+	option := Options{}
+	if c.Options != nil {
+		option = *c.Options
+	}
+	if option.DBPath == "" {
+		option.DBPath = defaultDBPath
+	}
+	option.DBPath = filepath.Join(option.DBPath, name)
+	if option.ReplicaPath != "" {
+		option.ReplicaPath = filepath.Join(option.ReplicaPath, name)
+	}
+	if option.Label == "" {
+		option.Label = name
 	}
+	c.Options = &option
+	return c
 }
 
 func validLoggerConfig(c Config) *Log {
@@ -152,26 +316,56 @@ func validLoggerConfig(c Config) *Log {
 }
 
 func validOptionData(c Config) *Options {
-	if c.Option == nil {
-		c.Option = &Options{
+	if c.Options == nil {
+		c.Options = &Options{
 			DBPath:   defaultDBPath,
 			MaxRetry: defaultMaxRetry,
 		}
 	}
-	if c.Option.DBPath == "" {
-		c.Option.DBPath = defaultDBPath
+	if c.Options.DBPath == "" {
+		c.Options.DBPath = defaultDBPath
+	}
+	if c.Options.MaxRetry <= 0 {
+		c.Options.MaxRetry = defaultMaxRetry
+	}
+	if c.Options.MaxQueue <= 0 {
+		c.Options.MaxQueue = defaultMaxQueue
+	}
+	if c.Options.MaxWorker <= 0 {
+		c.Options.MaxWorker = defaultMaxWorker
+	}
+	if c.Options.MaxWriteConcurrency <= 0 {
+		c.Options.MaxWriteConcurrency = c.Options.MaxWorker
+	}
+	if c.Options.DetectErrorRate <= 0 || c.Options.DetectErrorRate > 1 {
+		c.Options.DetectErrorRate = defaultDetectErrorRate
+	}
+	if c.Options.PublishBuffer <= 0 {
+		c.Options.PublishBuffer = defaultPublishBuffer
+	}
+	if c.Options.TimeToWrite <= 0 {
+		c.Options.TimeToWrite = defaultTimeToWrite
+	}
+	if c.Options.TimeToCheck <= 0 {
+		c.Options.TimeToCheck = defaultTimeToCheck
+	}
+	if c.Options.FileMode == 0 {
+		c.Options.FileMode = defaultFileMode
+	}
+	if c.Options.DirMode == 0 {
+		c.Options.DirMode = defaultDirMode
 	}
-	if c.Option.MaxRetry <= 0 {
-		c.Option.MaxRetry = defaultMaxRetry
+	if c.Options.TimeToPrune <= 0 {
+		c.Options.TimeToPrune = defaultTimeToPrune
 	}
-	if c.Option.MaxQueue <= 0 {
-		c.Option.MaxQueue = defaultMaxQueue
+	if c.Options.Encoder == nil {
+		c.Options.Encoder = stdEncoder{}
 	}
-	if c.Option.TimeToWrite <= 0 {
-		c.Option.TimeToWrite = defaultTimeToWrite
+	if c.Options.DiskErrorBudget <= 0 {
+		c.Options.DiskErrorBudget = defaultDiskErrorBudget
 	}
-	if c.Option.TimeToCheck <= 0 {
-		c.Option.TimeToCheck = defaultTimeToCheck
+	if c.Options.Clock == nil {
+		c.Options.Clock = realClock{}
 	}
-	return c.Option
+	return c.Options
 }
@@ -0,0 +1,49 @@
+package redisFallback
+
+import (
+	"fmt"
+	"regexp"
+	"unicode"
+)
+
+type KeyPolicy struct {
+	MaxLength    int            // 金鑰最大長度（位元組），0 不限制
+	Pattern      *regexp.Regexp // 允許的金鑰格式，nil 不限制
+	AllowControl bool           // 允許控制字元，預設拒絕
+}
+
+// * 空金鑰一律拒絕，其餘依 KeyPolicy 檢查
+func (rf *RedisFallback) validateKey(op string, key string) error {
+	if err := checkKey(rf.config.Options.KeyPolicy, key); err != nil {
+		return newOpError(rf.logger, op, key, "", err)
+	}
+	return nil
+}
+
+func checkKey(policy *KeyPolicy, key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+
+	if policy == nil {
+		policy = &KeyPolicy{}
+	}
+
+	if policy.MaxLength > 0 && len(key) > policy.MaxLength {
+		return fmt.Errorf("%w: length %d exceeds %d", ErrInvalidKey, len(key), policy.MaxLength)
+	}
+
+	if !policy.AllowControl {
+		for _, r := range key {
+			if unicode.IsControl(r) {
+				return fmt.Errorf("%w: contains control character %U", ErrInvalidKey, r)
+			}
+		}
+	}
+
+	if policy.Pattern != nil && !policy.Pattern.MatchString(key) {
+		return fmt.Errorf("%w: does not match %s", ErrInvalidKey, policy.Pattern)
+	}
+
+	return nil
+}
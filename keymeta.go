@@ -0,0 +1,88 @@
+package redisFallback
+
+import (
+	"context"
+	"time"
+)
+
+// * 金鑰是否存在，降級時檢查記憶體與本地檔案
+func (rf *RedisFallback) Exists(key string) (bool, error) {
+	if err := rf.validateKey("exists", key); err != nil {
+		return false, err
+	}
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var n int64
+			n, err = rf.redis.Exists(ctx, key).Result()
+			if err == nil {
+				return n > 0, nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("exists", key, err); err != nil {
+			return false, err
+		}
+	}
+
+	_, ok := rf.localItem(key)
+	return ok, nil
+}
+
+// * 剩餘存活時間，-1 代表不過期，金鑰不存在時回傳 ErrNotFound
+func (rf *RedisFallback) TTL(key string) (time.Duration, error) {
+	if err := rf.validateKey("ttl", key); err != nil {
+		return 0, err
+	}
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var pttl time.Duration
+			pttl, err = rf.redis.PTTL(ctx, key).Result()
+			if rf.stopRetry(err) {
+				break
+			}
+			if err != nil {
+				continue
+			}
+			// * -2: key does not exist
+			if pttl == -2 {
+				return 0, newOpError(rf.logger, "ttl", key, TierRedis, ErrNotFound)
+			}
+			return pttl, nil
+		}
+
+		if err := rf.redisFailed("ttl", key, err); err != nil {
+			return 0, err
+		}
+	}
+
+	item, ok := rf.localItem(key)
+	if !ok {
+		return 0, newOpError(rf.logger, "ttl", key, TierFile, ErrNotFound)
+	}
+	if item.TTL <= 0 {
+		return -1, nil
+	}
+	return remainingTTL(item, rf.now()), nil
+}
+
+// * 設定存活時間，ttl <= 0 時與 Redis 相同直接刪除金鑰
+func (rf *RedisFallback) Expire(key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return rf.Del(key)
+	}
+	return rf.ExpireMany(map[string]time.Duration{key: ttl})[key]
+}
+
+// * 移除到期時間
+func (rf *RedisFallback) Persist(key string) error {
+	return rf.ExpireMany(map[string]time.Duration{key: 0})[key]
+}
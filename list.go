@@ -0,0 +1,310 @@
+package redisFallback
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const listType = "list" // 降級期間推入的清單元素，復原時以 LPUSH / RPUSH 補回
+
+type lists struct {
+	mutex sync.Mutex
+}
+
+// * 推入清單開頭，回傳推入後的長度；降級時為本地緩衝的長度
+func (rf *RedisFallback) LPush(key string, values ...interface{}) (int64, error) {
+	return rf.push(key, true, values)
+}
+
+// * 推入清單結尾，回傳推入後的長度；降級時為本地緩衝的長度
+func (rf *RedisFallback) RPush(key string, values ...interface{}) (int64, error) {
+	return rf.push(key, false, values)
+}
+
+func (rf *RedisFallback) push(key string, head bool, values []interface{}) (int64, error) {
+	op := "rpush"
+	if head {
+		op = "lpush"
+	}
+	if err := rf.validateKey(op, key); err != nil {
+		return 0, err
+	}
+	if err := rf.checkWritable(op, key); err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		args, err := rf.listArgs(values)
+		if err != nil {
+			return 0, newOpError(rf.logger, op, key, TierRedis, parseError(err))
+		}
+
+		ctx := context.Background()
+		for i := 0; rf.canRetry(i); i++ {
+			var length int64
+			if head {
+				length, err = rf.redis.LPush(ctx, key, args...).Result()
+			} else {
+				length, err = rf.redis.RPush(ctx, key, args...).Result()
+			}
+			if err == nil {
+				return length, nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed(op, key, err); err != nil {
+			return 0, err
+		}
+	}
+
+	return rf.pushLocal(key, head, values)
+}
+
+// * Data 為本地緩衝的元素，前 Head 個由 LPush 推入，其餘由 RPush 推入
+func (rf *RedisFallback) pushLocal(key string, head bool, values []interface{}) (int64, error) {
+	rf.lists.mutex.Lock()
+	defer rf.lists.mutex.Unlock()
+
+	item, err := rf.localList(key)
+	if err != nil {
+		return 0, err
+	}
+
+	list := item.Data.([]interface{})
+	if head {
+		// * LPUSH a b c leaves c b a at the head
+		prefix := make([]interface{}, len(values))
+		for i, value := range values {
+			prefix[len(values)-1-i] = value
+		}
+		list = append(prefix, list...)
+		item.Head += len(values)
+	} else {
+		list = append(list, values...)
+	}
+	item.Data = list
+	item.Timestamp = rf.now().Unix()
+
+	if err := rf.setToMemory(context.Background(), key, item, PriorityNormal); err != nil {
+		return 0, err
+	}
+	return int64(len(list)), nil
+}
+
+// * 取得 start 到 stop 的元素（含 stop），負數由結尾起算；降級時只包含本地緩衝的元素
+func (rf *RedisFallback) LRange(key string, start int64, stop int64) ([]interface{}, error) {
+	if err := rf.validateKey("lrange", key); err != nil {
+		return nil, err
+	}
+
+	rf.metrics.gets.Add(1)
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var result []string
+			result, err = rf.redis.LRange(ctx, key, start, stop).Result()
+			if rf.stopRetry(err) {
+				break
+			}
+			if err != nil {
+				continue
+			}
+
+			list := make([]interface{}, len(result))
+			for j, data := range result {
+				if err := rf.config.Options.Encoder.Unmarshal([]byte(data), &list[j]); err != nil {
+					return nil, newOpError(rf.logger, "lrange", key, TierRedis, parseError(err))
+				}
+			}
+			rf.metrics.hit(TierRedis)
+			return list, nil
+		}
+
+		if err := rf.redisFailed("lrange", key, err); err != nil {
+			return nil, err
+		}
+	}
+
+	rf.lists.mutex.Lock()
+	item, err := rf.localList(key)
+	rf.lists.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	list := item.Data.([]interface{})
+	from, to := listRange(int64(len(list)), start, stop)
+	if from > to {
+		return []interface{}{}, nil
+	}
+	result := make([]interface{}, to-from+1)
+	copy(result, list[from:to+1])
+	return result, nil
+}
+
+// * 取出清單開頭的元素；降級時從本地緩衝取出，取出的元素不會補回 Redis
+func (rf *RedisFallback) LPop(key string) (interface{}, error) {
+	if err := rf.validateKey("lpop", key); err != nil {
+		return nil, err
+	}
+	if err := rf.checkWritable("lpop", key); err != nil {
+		return nil, err
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var result string
+			result, err = rf.redis.LPop(ctx, key).Result()
+			if err == redis.Nil {
+				return nil, newOpError(rf.logger, "lpop", key, TierRedis, ErrNotFound)
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+			if err != nil {
+				continue
+			}
+
+			var value interface{}
+			if err := rf.config.Options.Encoder.Unmarshal([]byte(result), &value); err != nil {
+				return nil, newOpError(rf.logger, "lpop", key, TierRedis, parseError(err))
+			}
+			return value, nil
+		}
+
+		if err := rf.redisFailed("lpop", key, err); err != nil {
+			return nil, err
+		}
+	}
+
+	rf.lists.mutex.Lock()
+	defer rf.lists.mutex.Unlock()
+
+	item, err := rf.localList(key)
+	if err != nil {
+		return nil, err
+	}
+	list := item.Data.([]interface{})
+	if len(list) == 0 {
+		return nil, newOpError(rf.logger, "lpop", key, TierMemory, ErrNotFound)
+	}
+
+	value := list[0]
+	item.Data = list[1:]
+	if item.Head > 0 {
+		item.Head--
+	}
+	item.Timestamp = rf.now().Unix()
+
+	if err := rf.setToMemory(context.Background(), key, item, PriorityNormal); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// * 需持有 lists.mutex；沒有本地緩衝時回傳空清單
+func (rf *RedisFallback) localList(key string) (Cache, error) {
+	item := Cache{Key: key, Type: listType, Data: []interface{}{}, Timestamp: rf.now().Unix()}
+
+	old, ok := rf.localItem(key)
+	if !ok {
+		return item, nil
+	}
+	if old.Type != listType {
+		return item, newOpError(rf.logger, "list", key, TierMemory, fmt.Errorf("%w: %T is not a list", ErrType, old.Data))
+	}
+
+	// * Copy, the stored slice may be shared with readers
+	if list, ok := old.Data.([]interface{}); ok {
+		item.Data = append([]interface{}{}, list...)
+	}
+	item.Head = min(old.Head, len(item.Data.([]interface{})))
+	return item, nil
+}
+
+// * 與 LRANGE 相同的索引規則，回傳夾在範圍內的 from 與 to
+func listRange(length int64, start int64, stop int64) (int64, int64) {
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	stop = min(stop, length-1)
+	return start, stop
+}
+
+func (rf *RedisFallback) listArgs(values []interface{}) ([]interface{}, error) {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		data, err := rf.config.Options.Encoder.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = string(data)
+	}
+	return args, nil
+}
+
+// * 先以反序 LPUSH 還原開頭，再 RPUSH 結尾，不覆蓋 Redis 中既有的元素
+func (rf *RedisFallback) writeList(ctx context.Context, c redis.Cmdable, key string, item Cache) (redis.Cmder, error) {
+	list, _ := item.Data.([]interface{})
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%w: empty list", ErrType)
+	}
+	head := min(item.Head, len(list))
+
+	var cmd redis.Cmder
+	if head > 0 {
+		args, err := rf.listArgs(list[:head])
+		if err != nil {
+			return nil, err
+		}
+		for i, j := 0, len(args)-1; i < j; i, j = i+1, j-1 {
+			args[i], args[j] = args[j], args[i]
+		}
+		cmd = c.LPush(ctx, key, args...)
+	}
+	if head < len(list) {
+		args, err := rf.listArgs(list[head:])
+		if err != nil {
+			return nil, err
+		}
+		cmd = c.RPush(ctx, key, args...)
+	}
+	return cmd, nil
+}
+
+// * 緩衝已補回 Redis，未再變動時移除，避免下次復原重複推入
+func (rf *RedisFallback) settleList(key string, item Cache) {
+	rf.lists.mutex.Lock()
+	defer rf.lists.mutex.Unlock()
+
+	cached, ok := rf.cache.Load(key)
+	if !ok {
+		return
+	}
+	current := cached.(Cache)
+	if current.Type != listType || current.Timestamp != item.Timestamp || current.Head != item.Head {
+		return
+	}
+	if list, ok := current.Data.([]interface{}); !ok || len(list) != len(item.Data.([]interface{})) {
+		return
+	}
+	rf.writer.remove(key)
+	rf.deleteCache(key)
+	rf.removeJSONFile(key)
+}
@@ -0,0 +1,61 @@
+package redisFallback
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errLoaderPanic = errors.New("loader panicked")
+
+// * 同一金鑰同時間只執行一次 loader，其餘呼叫等待並共用結果
+type loaders struct {
+	mutex sync.Mutex
+	calls map[string]*loaderCall
+}
+
+type loaderCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// * 讀取金鑰，不存在時以 loader 產生並寫入；正常與降級模式皆適用
+// * loader 回傳錯誤時不寫入，寫入失敗時仍回傳 loader 的值與寫入錯誤
+func (rf *RedisFallback) GetOrSet(key string, ttl time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+	value, err := rf.Get(key)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return value, err
+	}
+
+	rf.loaders.mutex.Lock()
+	if rf.loaders.calls == nil {
+		rf.loaders.calls = make(map[string]*loaderCall)
+	}
+	// * Another goroutine is already loading this key
+	if call, ok := rf.loaders.calls[key]; ok {
+		rf.loaders.mutex.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &loaderCall{done: make(chan struct{})}
+	rf.loaders.calls[key] = call
+	rf.loaders.mutex.Unlock()
+
+	// * Waiters must be released even if the loader panics
+	call.err = errLoaderPanic
+	defer func() {
+		rf.loaders.mutex.Lock()
+		delete(rf.loaders.calls, key)
+		rf.loaders.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = loader()
+	if call.err != nil {
+		call.value = nil
+		return nil, call.err
+	}
+	call.err = rf.Set(key, call.value, ttl)
+	return call.value, call.err
+}
@@ -0,0 +1,77 @@
+package redisFallback
+
+import (
+	"crypto/md5"
+	"fmt"
+	"sync"
+)
+
+const (
+	KeyRedactionHash     = "hash"     // 以 MD5 前 8 碼取代金鑰
+	KeyRedactionTruncate = "truncate" // 只保留金鑰前 8 個字元
+)
+
+// * 包裝 go-logger，加上實例標籤與金鑰遮蔽
+type logger struct {
+	*Logger
+	label     string
+	redaction string
+	clock     Clock
+	mutex     sync.Mutex
+	errors    []StatusError
+}
+
+func (l *logger) prefix(messages []any) []any {
+	if l.label == "" || len(messages) == 0 {
+		return messages
+	}
+	list := make([]any, len(messages))
+	copy(list, messages)
+	list[0] = fmt.Sprintf("[%s] %v", l.label, list[0])
+	return list
+}
+
+func (l *logger) Info(messages ...any) {
+	l.Logger.Info(l.prefix(messages)...)
+}
+
+func (l *logger) Warn(messages ...any) {
+	l.Logger.Warn(l.prefix(messages)...)
+}
+
+func (l *logger) Error(err error, messages ...any) error {
+	e := l.Logger.Error(err, l.prefix(messages)...)
+
+	// * Keep the latest errors for Status
+	l.mutex.Lock()
+	l.errors = append(l.errors, StatusError{Time: l.clock.Now().Unix(), Message: e.Error()})
+	if len(l.errors) > maxLastErrors {
+		l.errors = l.errors[len(l.errors)-maxLastErrors:]
+	}
+	l.mutex.Unlock()
+
+	return e
+}
+
+func (l *logger) lastErrors() []StatusError {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	list := make([]StatusError, len(l.errors))
+	copy(list, l.errors)
+	return list
+}
+
+func (l *logger) key(key string) string {
+	switch l.redaction {
+	case KeyRedactionHash:
+		return fmt.Sprintf("%x", md5.Sum([]byte(key)))[0:8]
+	case KeyRedactionTruncate:
+		if len(key) > 8 {
+			return key[0:8] + "..."
+		}
+		return key
+	default:
+		return key
+	}
+}
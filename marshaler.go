@@ -0,0 +1,129 @@
+package redisFallback
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// * 值可自行決定儲存格式
+type CacheMarshaler interface {
+	MarshalCache() ([]byte, error)
+}
+
+// * 指標型別實作後，透過 RegisterType 註冊即可在讀取時還原
+type CacheUnmarshaler interface {
+	UnmarshalCache(data []byte) error
+}
+
+type Marshaler struct {
+	Marshal   func(v interface{}) ([]byte, error)
+	Unmarshal func(data []byte) (interface{}, error)
+}
+
+type marshalers struct {
+	list sync.Map
+}
+
+// * 註冊特定型別的序列化函式
+func (rf *RedisFallback) RegisterMarshaler(sample interface{}, m Marshaler) {
+	rf.marshalers.list.Store(reflect.TypeOf(sample).String(), m)
+}
+
+// * 註冊實作 CacheMarshaler / CacheUnmarshaler 的型別
+func (rf *RedisFallback) RegisterType(sample interface{}) error {
+	t := reflect.TypeOf(sample)
+	if _, ok := reflect.New(t).Interface().(CacheUnmarshaler); !ok {
+		return rf.logger.Error(nil, fmt.Sprintf("*%s does not implement CacheUnmarshaler", t))
+	}
+
+	rf.RegisterMarshaler(sample, Marshaler{
+		Marshal: func(v interface{}) ([]byte, error) {
+			if m, ok := v.(CacheMarshaler); ok {
+				return m.MarshalCache()
+			}
+			return nil, fmt.Errorf("%T does not implement CacheMarshaler", v)
+		},
+		Unmarshal: func(data []byte) (interface{}, error) {
+			ptr := reflect.New(t)
+			if err := ptr.Interface().(CacheUnmarshaler).UnmarshalCache(data); err != nil {
+				return nil, err
+			}
+			return ptr.Elem().Interface(), nil
+		},
+	})
+	return nil
+}
+
+func (m *marshalers) encode(item Cache) (Cache, error) {
+	// * Stored as JSON null, custom marshalers are not called with nil
+	if isNilValue(item.Data) {
+		item.Data = nil
+		return item, nil
+	}
+
+	var data []byte
+	var err error
+
+	if value, ok := m.list.Load(item.Type); ok {
+		data, err = value.(Marshaler).Marshal(item.Data)
+	} else if marshaler, ok := item.Data.(CacheMarshaler); ok {
+		data, err = marshaler.MarshalCache()
+	} else {
+		return item, nil
+	}
+
+	if err != nil {
+		return item, err
+	}
+	item.Data = string(data)
+	return item, nil
+}
+
+func (m *marshalers) decode(item Cache) (Cache, error) {
+	str, ok := item.Data.(string)
+	if !ok {
+		return item, nil
+	}
+
+	value, ok := m.list.Load(item.Type)
+	if !ok {
+		return item, nil
+	}
+
+	data, err := value.(Marshaler).Unmarshal([]byte(str))
+	if err != nil {
+		return item, err
+	}
+	item.Data = data
+	return item, nil
+}
+
+func encodeCache(config Config, m *marshalers, cache Cache) ([]byte, error) {
+	cache, err := m.encode(cache)
+	if err != nil {
+		return nil, err
+	}
+	return config.Options.Encoder.Marshal(cache)
+}
+
+// * 本地檔案的內容，依 Options.Compression 壓縮
+func encodeFile(config Config, m *marshalers, cache Cache) ([]byte, error) {
+	data, err := encodeCache(config, m, cache)
+	if err != nil {
+		return nil, err
+	}
+	return compress(config, data)
+}
+
+func decodeCache(config Config, m *marshalers, data []byte) (Cache, error) {
+	var item Cache
+	data, err := decompress(data)
+	if err != nil {
+		return item, err
+	}
+	if err := config.Options.Encoder.Unmarshal(data, &item); err != nil {
+		return item, err
+	}
+	return m.decode(item)
+}
@@ -0,0 +1,282 @@
+package redisFallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const setType = "set" // 以 SAdd 寫入的集合，Data 為成員的 map
+
+type members struct {
+	mutex sync.Mutex
+}
+
+// * 加入集合成員，回傳新加入的數量；降級時加入本地集合，復原時以 SADD 與 Redis 既有成員聯集
+func (rf *RedisFallback) SAdd(key string, values ...string) (int, error) {
+	if err := rf.validateKey("sadd", key); err != nil {
+		return 0, err
+	}
+	if err := rf.checkWritable("sadd", key); err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var count int64
+			count, err = rf.redis.SAdd(ctx, key, memberArgs(values)...).Result()
+			if rf.stopRetry(err) {
+				break
+			}
+			if err == nil {
+				if !rf.config.Options.DisableMirror {
+					rf.updateSet(key, values, nil, false)
+				}
+				return int(count), nil
+			}
+		}
+
+		if err := rf.redisFailed("sadd", key, err); err != nil {
+			return 0, err
+		}
+	}
+
+	return rf.updateSet(key, values, nil, true)
+}
+
+// * 取得集合的所有成員，依字母排序；降級時為本地集合
+func (rf *RedisFallback) SMembers(key string) ([]string, error) {
+	if err := rf.validateKey("smembers", key); err != nil {
+		return nil, err
+	}
+
+	rf.metrics.gets.Add(1)
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var result []string
+			result, err = rf.redis.SMembers(ctx, key).Result()
+			if rf.stopRetry(err) {
+				break
+			}
+			if err != nil {
+				continue
+			}
+			// * SMEMBERS returns an empty list for a missing key
+			if len(result) == 0 {
+				rf.metrics.misses.Add(1)
+				return nil, newOpError(rf.logger, "smembers", key, TierRedis, ErrNotFound)
+			}
+			sort.Strings(result)
+			rf.metrics.hit(TierRedis)
+			return result, nil
+		}
+
+		if err := rf.redisFailed("smembers", key, err); err != nil {
+			return nil, err
+		}
+	}
+
+	set, err := rf.localSet("smembers", key)
+	if err != nil {
+		rf.metrics.misses.Add(1)
+		return nil, err
+	}
+	return setList(set), nil
+}
+
+// * 檢查是否為集合成員，金鑰不存在時回傳 false
+func (rf *RedisFallback) SIsMember(key string, value string) (bool, error) {
+	if err := rf.validateKey("sismember", key); err != nil {
+		return false, err
+	}
+
+	rf.metrics.gets.Add(1)
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var ok bool
+			ok, err = rf.redis.SIsMember(ctx, key, value).Result()
+			if err == nil {
+				rf.metrics.hit(TierRedis)
+				return ok, nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("sismember", key, err); err != nil {
+			return false, err
+		}
+	}
+
+	set, err := rf.localSet("sismember", key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	_, ok := set[value]
+	return ok, nil
+}
+
+// * 移除集合成員，回傳實際移除的數量；降級時記錄移除的成員，復原時以 SREM 同步
+func (rf *RedisFallback) SRem(key string, values ...string) (int, error) {
+	if err := rf.validateKey("srem", key); err != nil {
+		return 0, err
+	}
+	if err := rf.checkWritable("srem", key); err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var count int64
+			count, err = rf.redis.SRem(ctx, key, memberArgs(values)...).Result()
+			if rf.stopRetry(err) {
+				break
+			}
+			if err == nil {
+				if !rf.config.Options.DisableMirror {
+					rf.updateSet(key, nil, values, false)
+				}
+				return int(count), nil
+			}
+		}
+
+		if err := rf.redisFailed("srem", key, err); err != nil {
+			return 0, err
+		}
+	}
+
+	return rf.updateSet(key, nil, values, true)
+}
+
+// * 與 updateHash 相同，複製後加入與移除成員，回傳加入或移除的數量
+// * persist 為 true 時寫入本地檔案，並記錄移除的成員供復原時 SREM
+func (rf *RedisFallback) updateSet(key string, add []string, del []string, persist bool) (int, error) {
+	rf.members.mutex.Lock()
+	defer rf.members.mutex.Unlock()
+
+	item := Cache{Key: key, Type: setType, Timestamp: rf.now().Unix()}
+	set := make(map[string]struct{})
+	removed := make(map[string]struct{})
+
+	if old, ok := rf.localItem(key); ok && old.Type == setType {
+		if old.TTL > 0 {
+			item.TTL = max(old.Timestamp+old.TTL-item.Timestamp, 1)
+		}
+		set = setMembers(old)
+		// * Redis already applied them while healthy, unless recovery has not synced them yet
+		if persist || rf.isDirty(key) {
+			for _, member := range old.Removed {
+				removed[member] = struct{}{}
+			}
+		}
+	}
+
+	count := 0
+	for _, member := range add {
+		if _, ok := set[member]; !ok {
+			set[member] = struct{}{}
+			count++
+		}
+		delete(removed, member)
+	}
+	for _, member := range del {
+		if _, ok := set[member]; ok {
+			delete(set, member)
+			count++
+		}
+		if persist {
+			removed[member] = struct{}{}
+		}
+	}
+
+	item.Data = set
+	for member := range removed {
+		item.Removed = append(item.Removed, member)
+	}
+
+	if !persist {
+		// * Nothing left to mirror, Redis dropped the key with its last member
+		if len(set) == 0 {
+			rf.deleteCache(key)
+			return count, nil
+		}
+		rf.storeCache(key, item)
+		return count, nil
+	}
+	return count, rf.setToMemory(context.Background(), key, item, PriorityNormal)
+}
+
+// * 本地集合的複本，空集合視為不存在
+func (rf *RedisFallback) localSet(op string, key string) (map[string]struct{}, error) {
+	rf.members.mutex.Lock()
+	item, ok := rf.localItem(key)
+	rf.members.mutex.Unlock()
+	if !ok {
+		return nil, newOpError(rf.logger, op, key, TierMemory, ErrNotFound)
+	}
+	if item.Type != setType {
+		return nil, newOpError(rf.logger, op, key, TierMemory, fmt.Errorf("%w: %T is not a set", ErrType, item.Data))
+	}
+
+	set := setMembers(item)
+	if len(set) == 0 {
+		return nil, newOpError(rf.logger, op, key, TierMemory, ErrNotFound)
+	}
+	return set, nil
+}
+
+// * 記憶體中為 map[string]struct{}，經過 JSON（檔案）後為 map[string]interface{}
+func setMembers(item Cache) map[string]struct{} {
+	set := make(map[string]struct{})
+	switch data := item.Data.(type) {
+	case map[string]struct{}:
+		for member := range data {
+			set[member] = struct{}{}
+		}
+	case map[string]interface{}:
+		for member := range data {
+			set[member] = struct{}{}
+		}
+	}
+	return set
+}
+
+// * 依字母排序的成員
+func setList(set map[string]struct{}) []string {
+	list := make([]string, 0, len(set))
+	for member := range set {
+		list = append(list, member)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func memberArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
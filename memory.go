@@ -0,0 +1,43 @@
+package redisFallback
+
+// * 估算記憶體層佔用的位元組數（金鑰長度 + 值序列化後長度）
+func (rf *RedisFallback) MemoryUsage() int64 {
+	return rf.memoryBytes.Load()
+}
+
+// * 超過 MaxItemSize 的值不放入記憶體層，回傳是否已存入
+func (rf *RedisFallback) storeCache(key string, item Cache) bool {
+	size := estimateSize(rf.config.Options.Encoder, key, item)
+	if max := rf.config.Options.MaxItemSize; max > 0 && size > max {
+		// * Drop the previous value to avoid serving a stale copy
+		rf.deleteCache(key)
+		return false
+	}
+
+	delta := size
+	keys := int64(1)
+	if old, loaded := rf.sizes.Swap(key, size); loaded {
+		delta -= old.(int64)
+		keys = 0
+	}
+	rf.memoryBytes.Add(delta)
+	rf.namespaces.resize(key, delta, keys)
+	rf.cache.Store(key, item)
+	return true
+}
+
+func (rf *RedisFallback) deleteCache(key string) {
+	rf.cache.Delete(key)
+	if old, loaded := rf.sizes.LoadAndDelete(key); loaded {
+		rf.memoryBytes.Add(-old.(int64))
+		rf.namespaces.resize(key, -old.(int64), -1)
+	}
+}
+
+func estimateSize(encoder Encoder, key string, item Cache) int64 {
+	size := int64(len(key))
+	if data, err := encoder.Marshal(item.Data); err == nil {
+		size += int64(len(data))
+	}
+	return size
+}
@@ -0,0 +1,66 @@
+package redisFallback
+
+import (
+	"sync/atomic"
+)
+
+// * 操作計數，供 statsd 等外部監控使用
+type metrics struct {
+	gets           atomic.Int64
+	sets           atomic.Int64
+	dels           atomic.Int64
+	misses         atomic.Int64
+	hitsRedis      atomic.Int64
+	hitsMemory     atomic.Int64
+	hitsFile       atomic.Int64
+	fallbacks      atomic.Int64
+	recoveries     atomic.Int64
+	deduped        atomic.Int64
+	throttled      atomic.Int64 // 重試額度用完而放棄的重試
+	publishDropped atomic.Int64 // 降級期間緩衝已滿而丟棄的最舊訊息
+}
+
+func (m *metrics) hit(tier string) {
+	switch tier {
+	case TierRedis:
+		m.hitsRedis.Add(1)
+	case TierMemory:
+		m.hitsMemory.Add(1)
+	case TierFile:
+		m.hitsFile.Add(1)
+	}
+}
+
+func (rf *RedisFallback) counters() map[string]int64 {
+	list := rf.metrics.counters()
+	// * merged: updates coalesced into a pending write, dropped: queue full and written directly
+	list["queue.merged"] = rf.writer.merged.Load()
+	list["queue.dropped"] = rf.writer.dropped.Load()
+	return list
+}
+
+// * 模式切換當下的計數與狀態，附加於事件與 Email 供事後檢討
+func (rf *RedisFallback) metricsSnapshot() map[string]int64 {
+	list := rf.counters()
+	list["memory_bytes"] = rf.MemoryUsage()
+	list["queue"] = int64(rf.backlog())
+	list["offline_writes"] = rf.offlineWrites.Load()
+	return list
+}
+
+func (m *metrics) counters() map[string]int64 {
+	return map[string]int64{
+		"gets":            m.gets.Load(),
+		"sets":            m.sets.Load(),
+		"dels":            m.dels.Load(),
+		"misses":          m.misses.Load(),
+		"hits.redis":      m.hitsRedis.Load(),
+		"hits.memory":     m.hitsMemory.Load(),
+		"hits.file":       m.hitsFile.Load(),
+		"fallbacks":       m.fallbacks.Load(),
+		"recoveries":      m.recoveries.Load(),
+		"deduped":         m.deduped.Load(),
+		"throttled":       m.throttled.Load(),
+		"publish.dropped": m.publishDropped.Load(),
+	}
+}
@@ -0,0 +1,93 @@
+package redisFallback
+
+import (
+	"context"
+)
+
+const (
+	TierRedis  = "redis"
+	TierMemory = "memory"
+	TierFile   = "file"
+)
+
+type MGetResult struct {
+	Value interface{} // 取得的值
+	Tier  string      // 值的來源層：redis / memory / file
+	Err   error       // 單一金鑰的錯誤
+}
+
+// * 批次取得，Redis 取不到的金鑰個別從記憶體或本地檔案補齊
+func (rf *RedisFallback) MGet(keys ...string) map[string]MGetResult {
+	rf.mutex.RLock()
+	isHealth := rf.isHealth
+	rf.mutex.RUnlock()
+
+	rf.metrics.gets.Add(int64(len(keys)))
+
+	results := make(map[string]MGetResult, len(keys))
+
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if err := rf.validateKey("mget", key); err != nil {
+			rf.metrics.misses.Add(1)
+			results[key] = MGetResult{Err: err}
+			continue
+		}
+		valid = append(valid, key)
+	}
+	keys = valid
+
+	if len(keys) == 0 {
+		return results
+	}
+
+	if isHealth {
+		ctx := context.Background()
+
+		var values []interface{}
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			values, err = rf.redis.MGet(ctx, keys...).Result()
+			if err == nil || rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err != nil {
+			if err := rf.redisFailed("mget", "", err); err != nil {
+				for _, key := range keys {
+					results[key] = MGetResult{Err: err}
+				}
+				return results
+			}
+		} else {
+			for i, value := range values {
+				str, ok := value.(string)
+				if !ok {
+					continue
+				}
+				if item, ok := rf.parseRedisValue(keys[i], str); ok {
+					rf.repairLocal(keys[i], item)
+					rf.metrics.hit(TierRedis)
+					rf.namespaces.read(keys[i], nil)
+					results[keys[i]] = MGetResult{Value: item.Data, Tier: TierRedis}
+				}
+			}
+		}
+	}
+
+	// * Fill missing keys from memory or local files
+	for _, key := range keys {
+		if _, ok := results[key]; ok {
+			continue
+		}
+		item, tier, err := rf.getFromMemory(context.Background(), key)
+		rf.namespaces.read(key, err)
+		if err != nil {
+			rf.metrics.misses.Add(1)
+		}
+		results[key] = MGetResult{Value: item.Data, Tier: tier, Err: err}
+	}
+
+	return results
+}
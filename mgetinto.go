@@ -0,0 +1,81 @@
+package redisFallback
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// * 批次取得並解碼至 slice，順序與 keys 相同，回傳找不到的金鑰
+func (rf *RedisFallback) MGetInto(keys []string, dest interface{}) ([]string, error) {
+	ptr := reflect.ValueOf(dest)
+	if ptr.Kind() != reflect.Pointer || ptr.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("%w: dest must be a pointer to slice, got %T", ErrType, dest)
+	}
+
+	slice := reflect.MakeSlice(ptr.Elem().Type(), len(keys), len(keys))
+	elemType := slice.Type().Elem()
+
+	var missing []string
+	results := rf.MGet(keys...)
+	for i, key := range keys {
+		result := results[key]
+		if result.Err != nil {
+			missing = append(missing, key)
+			continue
+		}
+		if err := rf.decodeInto(result.Value, slice.Index(i), elemType); err != nil {
+			return missing, newOpError(rf.logger, "mget", key, result.Tier, parseError(err))
+		}
+	}
+
+	ptr.Elem().Set(slice)
+	return missing, nil
+}
+
+// * 批次取得並解碼至 map[string]T，只包含找到的金鑰
+func (rf *RedisFallback) MGetIntoMap(keys []string, dest interface{}) ([]string, error) {
+	ptr := reflect.ValueOf(dest)
+	if ptr.Kind() != reflect.Pointer || ptr.Elem().Kind() != reflect.Map || ptr.Elem().Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("%w: dest must be a pointer to map[string]T, got %T", ErrType, dest)
+	}
+
+	mapValue := ptr.Elem()
+	if mapValue.IsNil() {
+		mapValue.Set(reflect.MakeMap(mapValue.Type()))
+	}
+	elemType := mapValue.Type().Elem()
+
+	var missing []string
+	results := rf.MGet(keys...)
+	for _, key := range keys {
+		result := results[key]
+		if result.Err != nil {
+			missing = append(missing, key)
+			continue
+		}
+		elem := reflect.New(elemType).Elem()
+		if err := rf.decodeInto(result.Value, elem, elemType); err != nil {
+			return missing, newOpError(rf.logger, "mget", key, result.Tier, parseError(err))
+		}
+		mapValue.SetMapIndex(reflect.ValueOf(key).Convert(mapValue.Type().Key()), elem)
+	}
+
+	return missing, nil
+}
+
+func (rf *RedisFallback) decodeInto(value interface{}, target reflect.Value, targetType reflect.Type) error {
+	if value != nil {
+		// * Value kept in memory with the original type
+		if v := reflect.ValueOf(value); v.Type().AssignableTo(targetType) {
+			target.Set(v)
+			return nil
+		}
+	}
+
+	// * Value parsed from JSON (map / []interface{} / float64), round-trip into the target type
+	data, err := rf.config.Options.Encoder.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return rf.config.Options.Encoder.Unmarshal(data, target.Addr().Interface())
+}
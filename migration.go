@@ -0,0 +1,168 @@
+package redisFallback
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	maxMigrationVerify     = 16 // 同時比對新舊值的最大數量，超過時略過該次比對
+	maxMigrationMismatches = 20 // 報告保留的最近不一致金鑰數
+)
+
+// * 寫入後需要複製到遷移目標的指令
+var migrationWrites = map[string]bool{
+	"set": true, "setex": true, "mset": true, "del": true, "unlink": true,
+	"hset": true, "hdel": true, "expire": true, "expireat": true, "pexpire": true,
+	"pexpireat": true, "persist": true, "eval": true, "evalsha": true, "script": true,
+}
+
+type MigrationReport struct {
+	Writes      int64    `json:"writes"`       // 已複製到新 Redis 的寫入數
+	WriteErrors int64    `json:"write_errors"` // 新 Redis 寫入失敗數
+	Verified    int64    `json:"verified"`     // 已比對的讀取數
+	Mismatches  int64    `json:"mismatches"`   // 新舊值不一致的次數
+	Recent      []string `json:"recent"`       // 最近不一致的金鑰
+}
+
+// * 遷移期間寫入同時送往新 Redis，讀取仍使用原本的 Redis，並在背景與新 Redis 比對
+type migration struct {
+	logger      *logger
+	target      *redis.Client
+	slots       chan struct{}
+	writes      atomic.Int64
+	writeErrors atomic.Int64
+	verified    atomic.Int64
+	mismatches  atomic.Int64
+	mutex       sync.Mutex
+	recent      []string
+}
+
+func newMigration(c Config, logger *logger) *migration {
+	target := c.Options.MigrateTo
+	if target == nil {
+		return nil
+	}
+
+	creds := &credentials{username: target.Username, password: target.Password}
+	return &migration{
+		logger: logger,
+		target: initRedis(Config{Redis: target}, creds, 0),
+		slots:  make(chan struct{}, maxMigrationVerify),
+	}
+}
+
+func (m *migration) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (m *migration) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		m.mirror(ctx, []redis.Cmder{cmd})
+		return err
+	}
+}
+
+func (m *migration) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		m.mirror(ctx, cmds)
+		return err
+	}
+}
+
+// * 只複製在原本 Redis 成功的寫入，新 Redis 的錯誤不影響呼叫端
+func (m *migration) mirror(ctx context.Context, cmds []redis.Cmder) {
+	var writes []redis.Cmder
+	for _, cmd := range cmds {
+		if cmd.Name() == "get" {
+			m.verify(cmd)
+			continue
+		}
+		if migrationWrites[cmd.Name()] && cmd.Err() == nil {
+			writes = append(writes, cmd)
+		}
+	}
+	if len(writes) == 0 {
+		return
+	}
+
+	pipe := m.target.Pipeline()
+	for _, cmd := range writes {
+		pipe.Do(ctx, cmd.Args()...)
+	}
+	results, _ := pipe.Exec(ctx)
+	for _, result := range results {
+		m.writes.Add(1)
+		if err := result.Err(); err != nil && err != redis.Nil {
+			m.writeErrors.Add(1)
+			m.logger.Error(err, "Failed to write to migration target")
+		}
+	}
+}
+
+// * 背景比對，寫入同時進行時可能出現短暫的不一致
+func (m *migration) verify(cmd redis.Cmder) {
+	get, ok := cmd.(*redis.StringCmd)
+	if !ok || (get.Err() != nil && get.Err() != redis.Nil) {
+		return
+	}
+	key, ok := get.Args()[1].(string)
+	if !ok {
+		return
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return
+	}
+
+	source, sourceErr := get.Val(), get.Err()
+	go func() {
+		defer func() { <-m.slots }()
+
+		target, err := m.target.Get(context.Background(), key).Result()
+		if err != nil && err != redis.Nil {
+			return
+		}
+
+		m.verified.Add(1)
+		if target == source && (err == redis.Nil) == (sourceErr == redis.Nil) {
+			return
+		}
+
+		m.mismatches.Add(1)
+		m.logger.Warn("Migration target mismatch", m.logger.key(key))
+		m.mutex.Lock()
+		m.recent = append(m.recent, key)
+		if len(m.recent) > maxMigrationMismatches {
+			m.recent = m.recent[len(m.recent)-maxMigrationMismatches:]
+		}
+		m.mutex.Unlock()
+	}()
+}
+
+// * 遷移的寫入與比對結果，未設定 MigrateTo 時回傳空報告
+func (rf *RedisFallback) MigrationReport() MigrationReport {
+	m := rf.migration
+	if m == nil {
+		return MigrationReport{}
+	}
+
+	m.mutex.Lock()
+	recent := append([]string(nil), m.recent...)
+	m.mutex.Unlock()
+
+	return MigrationReport{
+		Writes:      m.writes.Load(),
+		WriteErrors: m.writeErrors.Load(),
+		Verified:    m.verified.Load(),
+		Mismatches:  m.mismatches.Load(),
+		Recent:      recent,
+	}
+}
@@ -0,0 +1,184 @@
+package redisFallback
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// * 以單一健康檢查管理多個 Redis DB，各 DB 使用各自的本地目錄 {DBPath}/{db}
+type Multi struct {
+	instances map[int]*RedisFallback
+	shared    *shared
+	checker   Ticker
+	timer     Ticker
+	closed    chan struct{}
+}
+
+// * Multi 各 DB 共用的資源；待寫入佇列、本地目錄與 Redis 連線依 DB 區分。方法在 shared 為 nil（單一實例）時建立各自的資源
+type shared struct {
+	dbs     int
+	logger  *logger
+	slots   chan struct{} // 所有 DB 合計最多 MaxWriteConcurrency 個檔案同時寫入
+	kick    chan struct{}
+	replica *replicaQueue
+}
+
+func (s *shared) getLogger(c Config) (*logger, error) {
+	if s == nil || s.logger == nil {
+		logger, err := newLogger(c)
+		if s != nil && err == nil {
+			s.logger = logger
+		}
+		return logger, err
+	}
+	return s.logger, nil
+}
+
+func (s *shared) getSlots(c Config) chan struct{} {
+	if s == nil || s.slots == nil {
+		slots := make(chan struct{}, c.Options.MaxWriteConcurrency)
+		if s != nil {
+			s.slots = slots
+		}
+		return slots
+	}
+	return s.slots
+}
+
+func (s *shared) getKick() chan struct{} {
+	if s == nil {
+		return make(chan struct{}, 1)
+	}
+	return s.kick
+}
+
+func (s *shared) getReplica() *replicaQueue {
+	if s.replica == nil {
+		s.replica = newReplicaQueue()
+	}
+	return s.replica
+}
+
+// * 各 DB 的連線池合計與單一實例的 go-redis 預設（每個 CPU 10 條）相同
+func (s *shared) poolSize() int {
+	if s == nil {
+		return 0
+	}
+	return max(1, 10*runtime.GOMAXPROCS(0)/s.dbs)
+}
+
+func NewMulti(c Config, dbs ...int) (*Multi, error) {
+	if len(dbs) == 0 {
+		return nil, fmt.Errorf("At least one DB is required")
+	}
+	if c.Redis == nil {
+		c.Redis = &Redis{}
+	}
+
+	m := &Multi{
+		instances: make(map[int]*RedisFallback, len(dbs)),
+		shared:    &shared{dbs: len(dbs), kick: make(chan struct{}, 1)},
+		closed:    make(chan struct{}),
+	}
+
+	for _, db := range dbs {
+		redisConfig := *c.Redis
+		redisConfig.DB = db
+
+		config := c
+		config.Redis = &redisConfig
+
+		rf, err := newInstance(config, m.shared)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.instances[db] = rf
+	}
+
+	option := m.first().config.Options
+	m.checker = option.Clock.NewTicker(option.TimeToCheck)
+	go m.check()
+	if fallbackDisk {
+		m.timer = option.Clock.NewTicker(option.TimeToWrite)
+		go m.write()
+		if m.shared.replica != nil {
+			go m.first().writer.startReplica(m.closed)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Multi) DB(db int) *RedisFallback {
+	return m.instances[db]
+}
+
+func (m *Multi) first() *RedisFallback {
+	for _, rf := range m.instances {
+		return rf
+	}
+	return nil
+}
+
+// * 同一台 Redis 只需 ping 一次，成功後復原所有降級中的 DB
+func (m *Multi) check() {
+	for {
+		select {
+		case <-m.closed:
+			m.checker.Stop()
+			return
+		case <-m.checker.C():
+			var list []*RedisFallback
+			for _, rf := range m.instances {
+				rf.mutex.RLock()
+				if !rf.isHealth && !rf.isRecovering.Load() {
+					list = append(list, rf)
+				}
+				rf.mutex.RUnlock()
+			}
+			if len(list) == 0 {
+				continue
+			}
+
+			if err := list[0].checkHealthy(context.Background()); err != nil {
+				continue
+			}
+			for _, rf := range list {
+				rf.heartbeat()
+				rf.mutex.Lock()
+				rf.changeToNormalMode("health check")
+				rf.mutex.Unlock()
+			}
+		}
+	}
+}
+
+// * 單一排程依序寫入各 DB 的待寫入資料，任一 DB 達到 FlushSize / FlushBytes 時全部寫入
+func (m *Multi) write() {
+	for {
+		select {
+		case <-m.closed:
+			m.timer.Stop()
+			return
+		case <-m.timer.C():
+		case <-m.shared.kick:
+		}
+		for _, rf := range m.instances {
+			rf.writer.write()
+		}
+	}
+}
+
+func (m *Multi) Close() {
+	select {
+	case <-m.closed:
+		return
+	default:
+		close(m.closed)
+	}
+	for _, rf := range m.instances {
+		rf.Close()
+	}
+}
@@ -0,0 +1,114 @@
+package redisFallback
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+type NamespaceStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Sets          int64 `json:"sets"`
+	MemoryBytes   int64 `json:"memory_bytes"`
+	MemoryKeys    int64 `json:"memory_keys"`
+	OfflineWrites int64 `json:"offline_writes"` // 本次降級期間的寫入數，復原後歸零
+	Rejected      int64 `json:"rejected"`       // 超過配額被拒絕的寫入數
+	Evicted       int64 `json:"evicted"`        // 超過配額被移出記憶體層的金鑰數
+}
+
+type namespaceCounter struct {
+	hits          atomic.Int64
+	misses        atomic.Int64
+	sets          atomic.Int64
+	memoryBytes   atomic.Int64
+	memoryKeys    atomic.Int64
+	offlineWrites atomic.Int64
+	rejected      atomic.Int64
+	evicted       atomic.Int64
+	prefix        string
+	quota         *Quota
+}
+
+// * 依 Options.Namespaces 的金鑰前綴分別計數，初始化後唯讀
+type namespaces struct {
+	prefixes map[string]string
+	counters map[string]*namespaceCounter
+}
+
+func newNamespaces(list map[string]string, quotas map[string]Quota) *namespaces {
+	n := &namespaces{
+		prefixes: list,
+		counters: make(map[string]*namespaceCounter, len(list)),
+	}
+	for name, prefix := range list {
+		n.counters[name] = &namespaceCounter{prefix: prefix}
+		if quota, ok := quotas[name]; ok {
+			n.counters[name].quota = &quota
+		}
+	}
+	return n
+}
+
+// * 以最長的相符前綴決定金鑰所屬的命名空間，不屬於任何命名空間時回傳 nil
+func (n *namespaces) lookup(key string) *namespaceCounter {
+	if n == nil {
+		return nil
+	}
+
+	var counter *namespaceCounter
+	longest := -1
+	for name, prefix := range n.prefixes {
+		if len(prefix) > longest && strings.HasPrefix(key, prefix) {
+			counter = n.counters[name]
+			longest = len(prefix)
+		}
+	}
+	return counter
+}
+
+func (n *namespaces) read(key string, err error) {
+	counter := n.lookup(key)
+	if counter == nil {
+		return
+	}
+	if err != nil {
+		counter.misses.Add(1)
+	} else {
+		counter.hits.Add(1)
+	}
+}
+
+func (n *namespaces) set(key string) {
+	if counter := n.lookup(key); counter != nil {
+		counter.sets.Add(1)
+	}
+}
+
+// * keys 為記憶體層金鑰數的變化：新增 1、刪除 -1、覆蓋 0
+func (n *namespaces) resize(key string, delta int64, keys int64) {
+	if counter := n.lookup(key); counter != nil {
+		counter.memoryBytes.Add(delta)
+		counter.memoryKeys.Add(keys)
+	}
+}
+
+func (n *namespaces) stats() map[string]NamespaceStats {
+	if n == nil || len(n.counters) == 0 {
+		return nil
+	}
+
+	list := make(map[string]NamespaceStats, len(n.counters))
+	for name, counter := range n.counters {
+		list[name] = NamespaceStats{
+			Hits:          counter.hits.Load(),
+			Misses:        counter.misses.Load(),
+			Sets:          counter.sets.Load(),
+			MemoryBytes:   counter.memoryBytes.Load(),
+			MemoryKeys:    counter.memoryKeys.Load(),
+			OfflineWrites: counter.offlineWrites.Load(),
+			Rejected:      counter.rejected.Load(),
+			Evicted:       counter.evicted.Load(),
+		}
+	}
+	return list
+}
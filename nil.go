@@ -0,0 +1,50 @@
+package redisFallback
+
+import (
+	"context"
+	"reflect"
+)
+
+const (
+	NilReject = "reject" // 回傳 ErrNilValue，不寫入
+	NilStore  = "store"  // 以 JSON null 儲存，Get 回傳 nil 且沒有錯誤
+	NilDelete = "delete" // 視為 Del
+)
+
+// * nil 或型別為指標、map、slice 等且值為 nil
+func isNilValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// * 依 Options.NilValue 處理 nil 值，回傳 true 時呼叫端不需繼續寫入
+func (rf *RedisFallback) handleNil(ctx context.Context, actor string, op string, key string, value interface{}) (bool, error) {
+	if !isNilValue(value) {
+		return false, nil
+	}
+
+	switch rf.config.Options.NilValue {
+	case NilStore:
+		return false, nil
+	case NilDelete:
+		return true, rf.del(ctx, actor, key)
+	default:
+		return true, newOpError(rf.logger, op, key, "", ErrNilValue)
+	}
+}
+
+// * 無型別的 nil 以 "nil" 標記，避免 reflect.TypeOf(nil).String() panic
+func typeName(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+	return reflect.TypeOf(value).String()
+}
@@ -0,0 +1,44 @@
+package redisFallback
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// * 金鑰對應的本地檔案路徑，相對於 {DBPath}/{db}，預設為 MD5 三層分片
+type PathResolver interface {
+	Resolve(key string) string
+}
+
+// * 以函式實作 PathResolver，例如依租戶分目錄
+type PathResolverFunc func(key string) string
+
+func (f PathResolverFunc) Resolve(key string) string {
+	return f(key)
+}
+
+var (
+	// * 不分片，所有檔案位於同一目錄，適合小型快取
+	FlatPaths PathResolver = PathResolverFunc(func(key string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(key))) + ".json"
+	})
+	// * 以跳脫後的金鑰為檔名，方便除錯（檔名長度受檔案系統限制）
+	ReadablePaths PathResolver = PathResolverFunc(func(key string) string {
+		return url.PathEscape(key) + ".json"
+	})
+)
+
+// * 自訂路徑必須位於 {DBPath}/{db} 之下並以 .json 結尾，否則使用預設分片
+func resolvePath(resolver PathResolver, key string) (string, bool) {
+	rel := filepath.Clean(resolver.Resolve(key))
+	if rel == "." || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if !strings.HasSuffix(rel, ".json") {
+		rel += ".json"
+	}
+	return rel, true
+}
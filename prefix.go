@@ -0,0 +1,79 @@
+package redisFallback
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// * 刪除符合前綴的金鑰，涵蓋記憶體、本地檔案與 Redis；每個金鑰與 Del 相同處理，降級時寫入刪除紀錄
+// * 降級時只刪除本地找得到的金鑰，回傳刪除的數量與第一個錯誤
+func (rf *RedisFallback) DelPrefix(prefix string) (int, error) {
+	// * An empty prefix matches every key, use Flush instead
+	if prefix == "" {
+		return 0, newOpError(rf.logger, "delprefix", prefix, TierMemory, fmt.Errorf("%w: empty prefix", ErrInvalidKey))
+	}
+
+	rf.index.load(rf.config, rf.marshalers)
+
+	keys := make(map[string]struct{})
+	for _, key := range rf.index.match(prefix) {
+		keys[key] = struct{}{}
+	}
+	rf.cache.Range(func(key, value interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			keys[key.(string)] = struct{}{}
+		}
+		return true
+	})
+
+	if rf.isHealthy() {
+		list, err := rf.scanPrefix(prefix)
+		if err != nil {
+			if err := rf.redisFailed("delprefix", prefix, err); err != nil {
+				return 0, err
+			}
+		}
+		for _, key := range list {
+			keys[key] = struct{}{}
+		}
+	}
+
+	ctx := context.Background()
+	count := 0
+	var first error
+	for key := range keys {
+		if err := rf.del(ctx, "", key); err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		count++
+	}
+	return count, first
+}
+
+// * 重試後仍失敗時回傳已找到的金鑰與錯誤
+func (rf *RedisFallback) scanPrefix(prefix string) ([]string, error) {
+	ctx := context.Background()
+	var keys []string
+	var err error
+	for i := 0; rf.canRetry(i); i++ {
+		keys = keys[:0]
+		iter := rf.redis.Scan(ctx, 0, escapePattern(prefix)+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err = iter.Err(); err == nil || rf.stopRetry(err) {
+			break
+		}
+	}
+	return keys, err
+}
+
+// * 跳脫 Redis glob 特殊字元
+func escapePattern(str string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return replacer.Replace(str)
+}
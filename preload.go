@@ -0,0 +1,68 @@
+package redisFallback
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type preloadFile struct {
+	path    string
+	modTime time.Time
+}
+
+// * 以降級模式啟動時，將最近寫入的檔案載入記憶體，最多 Preload 筆
+func (rf *RedisFallback) preloadFromFile() int {
+	limit := rf.config.Options.Preload
+	if limit <= 0 {
+		return 0
+	}
+
+	folderPath := filepath.Join(rf.config.Options.DBPath, strconv.Itoa(rf.config.Redis.DB))
+
+	var files []preloadFile
+	filepath.WalkDir(folderPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, preloadFile{path: path, modTime: info.ModTime()})
+		return nil
+	})
+
+	// * Most recently written first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	loaded := 0
+	for _, file := range files {
+		if loaded >= limit {
+			break
+		}
+
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			continue
+		}
+		item, err := decodeCache(rf.config, rf.marshalers, data)
+		if err != nil || isExpired(item, rf.now()) || item.Type == tombstoneType {
+			continue
+		}
+
+		if rf.storeCache(item.Key, item) {
+			rf.index.add(item.Key)
+			loaded++
+		}
+	}
+
+	rf.logger.Info("Preloaded from file", loaded)
+	return loaded
+}
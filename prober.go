@@ -0,0 +1,31 @@
+package redisFallback
+
+import (
+	"context"
+)
+
+// * 可替換的健康檢查，例如 INFO replication 狀態、sentinel 查詢或外部服務網格訊號
+type Prober interface {
+	CheckHealthy(ctx context.Context) error // 回傳 nil 視為可用
+}
+
+// * 以函式實作 Prober
+type ProberFunc func(ctx context.Context) error
+
+func (f ProberFunc) CheckHealthy(ctx context.Context) error {
+	return f(ctx)
+}
+
+// * 未設定 Prober 時使用 ping（Ring 逐一檢查節點）
+func (rf *RedisFallback) checkHealthy(ctx context.Context) error {
+	var err error
+	if rf.config.Options.Prober != nil {
+		err = rf.config.Options.Prober.CheckHealthy(ctx)
+	} else {
+		err = rf.ping(ctx)
+	}
+	if err == nil {
+		rf.heartbeat()
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package redisFallback
+
+import (
+	"sync"
+)
+
+// * 本地檔案的讀取次數，每個記憶體清理週期歸零
+type accessCounter struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+// * 讀取次數達 PromoteAfter 才放入記憶體層，避免冷資料佔用記憶體
+func (rf *RedisFallback) shouldPromote(key string) bool {
+	limit := rf.config.Options.PromoteAfter
+	if limit <= 1 {
+		return true
+	}
+
+	rf.access.mutex.Lock()
+	defer rf.access.mutex.Unlock()
+
+	if rf.access.counts == nil {
+		rf.access.counts = make(map[string]int)
+	}
+	rf.access.counts[key]++
+	if rf.access.counts[key] < limit {
+		return false
+	}
+	delete(rf.access.counts, key)
+	return true
+}
+
+func (c *accessCounter) reset() {
+	c.mutex.Lock()
+	c.counts = nil
+	c.mutex.Unlock()
+}
@@ -0,0 +1,79 @@
+package redisFallback
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeToPrune = 10 * time.Minute // 預設空目錄清理間隔
+	defaultPruneBatch  = 1000             // 每次清理最多移除的目錄數
+	maxPruneDepth      = 3                // 分片目錄深度（MD5 前 6 碼，三層）
+)
+
+func (rf *RedisFallback) startPrune() {
+	ticker := rf.config.Options.Clock.NewTicker(rf.config.Options.TimeToPrune)
+	rf.goroutine(func() {
+		for {
+			select {
+			case <-rf.closed:
+				ticker.Stop()
+				return
+			case <-ticker.C():
+				rf.prune(defaultPruneBatch)
+			}
+		}
+	})
+}
+
+// * 由下往上移除空的分片目錄，最多移除 limit 個，回傳移除數量
+func (rf *RedisFallback) prune(limit int) int {
+	root := filepath.Join(rf.config.Options.DBPath, strconv.Itoa(rf.config.Redis.DB))
+
+	var folders []string
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() || path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		// * Only shard folders, skip anything deeper; custom layouts have no fixed depth
+		if depth := len(strings.Split(rel, string(filepath.Separator))); rf.config.Options.PathResolver == nil && depth > maxPruneDepth {
+			return filepath.SkipDir
+		}
+		folders = append(folders, path)
+		return nil
+	})
+
+	removed := 0
+	// * Deepest folders come last in walk order, remove bottom-up
+	for i := len(folders) - 1; i >= 0 && removed < limit; i-- {
+		if rf.removeFolder(folders[i]) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// * 持有寫入鎖時才移除，避免與正在建立目錄並寫入的 writer 競爭
+func (rf *RedisFallback) removeFolder(path string) bool {
+	rf.writer.folderMutex.Lock()
+	defer rf.writer.folderMutex.Unlock()
+
+	entries, err := os.ReadDir(path)
+	if err != nil || len(entries) > 0 {
+		return false
+	}
+	if err := os.Remove(path); err != nil {
+		rf.logger.Error(err, "Failed to remove path")
+		return false
+	}
+	rf.writer.folders.Delete(path)
+	return true
+}
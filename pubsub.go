@@ -0,0 +1,400 @@
+package redisFallback
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultPublishBuffer      = 1000 // 每個頻道緩衝的訊息數上限
+	defaultSubscriptionBuffer = 100  // 每個訂閱尚未讀取的訊息數上限，滿時略過
+)
+
+const (
+	PublishDropOldest = "oldest" // 緩衝已滿時丟棄最舊的訊息
+	PublishDropNewest = "newest" // 緩衝已滿時拒絕新的訊息，回傳 ErrBufferFull
+)
+
+type Message struct {
+	Channel string `json:"channel"`
+	Payload string `json:"payload"`
+}
+
+// * 同一程序內的訂閱，Redis 正常時經由 Redis 接收，降級時直接接收本程序發布的訊息
+type Subscription struct {
+	C       <-chan Message
+	ch      chan Message
+	channel string
+	rf      *RedisFallback
+	once    sync.Once
+}
+
+type pubsub struct {
+	mutex    sync.Mutex
+	subs     map[string]map[*Subscription]struct{}
+	conn     *redis.PubSub
+	replayed map[Message]int              // 復原時補發、本地訂閱已收過的訊息，從 Redis 收到時略過
+	buffers  map[string][]bufferedMessage // 降級期間發布、尚未補發的訊息，與使用者的金鑰分開存放
+	lines    map[string]int               // 頻道記錄檔的行數，超過緩衝上限兩倍時改寫
+	seq      int64
+}
+
+type bufferedMessage struct {
+	seq     int64
+	payload string
+}
+
+// * 發布訊息；降級時立即送給本程序的訂閱，並緩衝於待寫入佇列，復原時依序補發至 Redis
+func (rf *RedisFallback) Publish(channel string, message string) error {
+	if err := rf.validateKey("publish", channel); err != nil {
+		return err
+	}
+	if err := rf.checkWritable("publish", channel); err != nil {
+		return err
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			err = rf.redis.Publish(ctx, channel, message).Err()
+			if err == nil {
+				return nil
+			}
+			// * Replicas accept PUBLISH, but keep the same behavior as other writes
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("publish", channel, err); err != nil {
+			return err
+		}
+	}
+
+	if err := rf.bufferMessage(channel, message); err != nil {
+		return err
+	}
+	rf.deliver(Message{Channel: channel, Payload: message})
+	return nil
+}
+
+// * 訂閱頻道，不再使用時呼叫 Close
+func (rf *RedisFallback) Subscribe(channel string) (*Subscription, error) {
+	if err := rf.validateKey("subscribe", channel); err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Message, defaultSubscriptionBuffer)
+	sub := &Subscription{C: ch, ch: ch, channel: channel, rf: rf}
+
+	rf.pubsub.mutex.Lock()
+	defer rf.pubsub.mutex.Unlock()
+
+	if rf.pubsub.subs == nil {
+		rf.pubsub.subs = make(map[string]map[*Subscription]struct{})
+	}
+	if rf.pubsub.subs[channel] == nil {
+		rf.pubsub.subs[channel] = make(map[*Subscription]struct{})
+		rf.subscribeRedis(channel)
+	}
+	rf.pubsub.subs[channel][sub] = struct{}{}
+	return sub, nil
+}
+
+// * 取消訂閱並關閉 C
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		rf := s.rf
+		rf.pubsub.mutex.Lock()
+		defer rf.pubsub.mutex.Unlock()
+
+		delete(rf.pubsub.subs[s.channel], s)
+		if len(rf.pubsub.subs[s.channel]) == 0 {
+			delete(rf.pubsub.subs, s.channel)
+			if rf.pubsub.conn != nil {
+				rf.pubsub.conn.Unsubscribe(context.Background(), s.channel)
+			}
+		}
+		close(s.ch)
+	})
+}
+
+// * 需持有 pubsub.mutex；所有訂閱共用一條連線，Redis 中斷期間由 go-redis 自動重連並重新訂閱
+func (rf *RedisFallback) subscribeRedis(channel string) {
+	ctx := context.Background()
+	if rf.pubsub.conn == nil {
+		rf.pubsub.conn = rf.redis.Subscribe(ctx)
+		messages := rf.pubsub.conn.Channel()
+		rf.goroutine(func() {
+			for msg := range messages {
+				rf.receive(Message{Channel: msg.Channel, Payload: msg.Payload})
+			}
+		})
+	}
+	// * Channel is kept even on error and subscribed again after reconnecting
+	if err := rf.pubsub.conn.Subscribe(ctx, channel); err != nil {
+		rf.logger.Error(err, "Failed to subscribe", channel)
+	}
+}
+
+func (rf *RedisFallback) receive(msg Message) {
+	rf.pubsub.mutex.Lock()
+	if rf.pubsub.replayed[msg] > 0 {
+		rf.pubsub.replayed[msg]--
+		if rf.pubsub.replayed[msg] == 0 {
+			delete(rf.pubsub.replayed, msg)
+		}
+		rf.pubsub.mutex.Unlock()
+		return
+	}
+	rf.pubsub.mutex.Unlock()
+	rf.deliver(msg)
+}
+
+// * 訂閱端來不及讀取時略過，不阻塞發布端
+func (rf *RedisFallback) deliver(msg Message) {
+	rf.pubsub.mutex.Lock()
+	defer rf.pubsub.mutex.Unlock()
+
+	for sub := range rf.pubsub.subs[msg.Channel] {
+		select {
+		case sub.ch <- msg:
+		default:
+			rf.logger.Info("Subscription buffer is full, skipped", msg.Channel)
+		}
+	}
+}
+
+// * 訊息緩衝不使用金鑰空間，存放於記憶體與 {DBPath}/pubsub/{db}，超過 PublishBuffer 時依 PublishDrop 處理
+func (rf *RedisFallback) bufferMessage(channel string, message string) error {
+	rf.pubsub.mutex.Lock()
+	defer rf.pubsub.mutex.Unlock()
+
+	list := rf.pubsub.buffers[channel]
+	if len(list) >= rf.config.Options.PublishBuffer {
+		if rf.config.Options.PublishDrop == PublishDropNewest {
+			return &OpError{Op: "publish", Key: channel, Tier: TierMemory, Err: ErrBufferFull}
+		}
+		list = list[len(list)-rf.config.Options.PublishBuffer+1:]
+		rf.metrics.publishDropped.Add(1)
+	}
+
+	if rf.pubsub.buffers == nil {
+		rf.pubsub.buffers = make(map[string][]bufferedMessage)
+		rf.pubsub.lines = make(map[string]int)
+	}
+	rf.pubsub.seq++
+	rf.pubsub.buffers[channel] = append(list, bufferedMessage{seq: rf.pubsub.seq, payload: message})
+	rf.offlineWrites.Add(1)
+	rf.appendMessage(channel, message)
+	return nil
+}
+
+// * 需持有 pubsub.mutex；每則訊息附加一行，磁碟無法使用時只保留在記憶體
+func (rf *RedisFallback) appendMessage(channel string, message string) {
+	if rf.writer.diskDown.Load() {
+		return
+	}
+	lines := rf.pubsub.lines[channel]
+	// * New file needs the channel line, a long file is rewritten with only the buffered messages
+	if lines == 0 || lines > 2*rf.config.Options.PublishBuffer {
+		rf.rewriteMessages(channel)
+		return
+	}
+
+	file, err := os.OpenFile(messagePath(rf.config, channel), os.O_WRONLY|os.O_APPEND, rf.config.Options.FileMode)
+	if err == nil {
+		_, err = file.WriteString(strconv.Quote(message) + "\n")
+		file.Close()
+	}
+	rf.writer.diskResult(err)
+	if err != nil {
+		rf.logger.Error(err, "Failed to write message", channel)
+		return
+	}
+	rf.pubsub.lines[channel]++
+}
+
+// * 需持有 pubsub.mutex；第一行為頻道名稱，其後為尚未補發的訊息，緩衝為空時移除
+func (rf *RedisFallback) rewriteMessages(channel string) {
+	if rf.writer.diskDown.Load() {
+		return
+	}
+	path := messagePath(rf.config, channel)
+	list := rf.pubsub.buffers[channel]
+	if len(list) == 0 {
+		os.Remove(path)
+		delete(rf.pubsub.lines, channel)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strconv.Quote(channel) + "\n")
+	for _, msg := range list {
+		sb.WriteString(strconv.Quote(msg.payload) + "\n")
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), rf.config.Options.DirMode)
+	// * Rename so a crash never leaves a half written buffer
+	if err == nil {
+		err = os.WriteFile(path+".tmp", []byte(sb.String()), rf.config.Options.FileMode)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	rf.writer.diskResult(err)
+	if err != nil {
+		rf.logger.Error(err, "Failed to write message", channel)
+		return
+	}
+	rf.pubsub.lines[channel] = len(list) + 1
+}
+
+// * 啟動時載入上次尚未補發的訊息，每個頻道保留最後 PublishBuffer 則
+func (rf *RedisFallback) loadMessages() {
+	files, err := filepath.Glob(filepath.Join(messageFolder(rf.config), "*.log"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+
+	rf.pubsub.mutex.Lock()
+	defer rf.pubsub.mutex.Unlock()
+
+	if rf.pubsub.buffers == nil {
+		rf.pubsub.buffers = make(map[string][]bufferedMessage)
+		rf.pubsub.lines = make(map[string]int)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			rf.logger.Error(err, "Failed to read messages", file)
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		channel, err := strconv.Unquote(lines[0])
+		if err != nil {
+			rf.logger.Info("Skipped unreadable file", file)
+			continue
+		}
+
+		var list []bufferedMessage
+		for _, line := range lines[1:] {
+			// * Last line cut short by a crash
+			message, err := strconv.Unquote(line)
+			if err != nil {
+				continue
+			}
+			rf.pubsub.seq++
+			list = append(list, bufferedMessage{seq: rf.pubsub.seq, payload: message})
+		}
+		list = list[max(len(list)-rf.config.Options.PublishBuffer, 0):]
+		rf.pubsub.buffers[channel] = list
+		rf.pubsub.lines[channel] = len(lines)
+	}
+}
+
+// * 依頻道以 pipeline 依序 PUBLISH，回傳成功與失敗的訊息數；本地訂閱已在降級時收過，從 Redis 收到時略過
+func (rf *RedisFallback) replayMessages() (int, int) {
+	rf.pubsub.mutex.Lock()
+	pending := make(map[string][]bufferedMessage, len(rf.pubsub.buffers))
+	for channel, list := range rf.pubsub.buffers {
+		pending[channel] = list
+	}
+	rf.pubsub.mutex.Unlock()
+
+	ctx := context.Background()
+	synced := 0
+	failed := 0
+	for channel, list := range pending {
+		if len(list) == 0 {
+			continue
+		}
+
+		pipe := rf.redis.Pipeline()
+		rf.pubsub.mutex.Lock()
+		_, subscribed := rf.pubsub.subs[channel]
+		if subscribed && rf.pubsub.replayed == nil {
+			rf.pubsub.replayed = make(map[Message]int)
+		}
+		for _, msg := range list {
+			pipe.Publish(ctx, channel, msg.payload)
+			if subscribed {
+				rf.pubsub.replayed[Message{Channel: channel, Payload: msg.payload}]++
+			}
+		}
+		rf.pubsub.mutex.Unlock()
+
+		if _, err := pipe.Exec(ctx); err != nil {
+			rf.logger.Error(err, "Failed to replay messages", channel)
+			failed += len(list)
+			continue
+		}
+		synced += len(list)
+		rf.settleMessages(channel, list[len(list)-1].seq)
+	}
+	return synced, failed
+}
+
+// * 已補發的訊息從緩衝移除，補發期間新發布的訊息保留到下次復原
+func (rf *RedisFallback) settleMessages(channel string, sent int64) {
+	rf.pubsub.mutex.Lock()
+	defer rf.pubsub.mutex.Unlock()
+
+	list := rf.pubsub.buffers[channel]
+	i := 0
+	for i < len(list) && list[i].seq <= sent {
+		i++
+	}
+	if i == len(list) {
+		delete(rf.pubsub.buffers, channel)
+	} else {
+		rf.pubsub.buffers[channel] = append([]bufferedMessage{}, list[i:]...)
+	}
+	rf.rewriteMessages(channel)
+}
+
+// * 匯出用的複本，頻道對應依序的訊息
+func (rf *RedisFallback) bufferedMessages() map[string][]string {
+	rf.pubsub.mutex.Lock()
+	defer rf.pubsub.mutex.Unlock()
+
+	list := make(map[string][]string, len(rf.pubsub.buffers))
+	for channel, messages := range rf.pubsub.buffers {
+		for _, msg := range messages {
+			list[channel] = append(list[channel], msg.payload)
+		}
+	}
+	return list
+}
+
+func messageFolder(config Config) string {
+	return filepath.Join(config.Options.DBPath, "pubsub", strconv.Itoa(config.Redis.DB))
+}
+
+func messagePath(config Config, channel string) string {
+	return filepath.Join(messageFolder(config), fmt.Sprintf("%x", md5.Sum([]byte(channel)))+".log")
+}
+
+func (rf *RedisFallback) closePubSub() {
+	rf.pubsub.mutex.Lock()
+	defer rf.pubsub.mutex.Unlock()
+
+	if rf.pubsub.conn != nil {
+		rf.pubsub.conn.Close()
+	}
+	for _, subs := range rf.pubsub.subs {
+		for sub := range subs {
+			sub.once.Do(func() { close(sub.ch) })
+		}
+	}
+	rf.pubsub.subs = nil
+}
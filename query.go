@@ -0,0 +1,123 @@
+package redisFallback
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	queryKeyPrefix = "sql:"    // 查詢結果的金鑰前綴，其後為 SQL 與參數的 SHA-256
+	queryTagPrefix = "sqltag:" // 標籤集合的金鑰前綴，成員為該標籤下的查詢金鑰
+)
+
+// * *sql.DB、*sql.Tx 與 *sql.Conn 皆符合
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// * 快取查詢結果，金鑰為 SQL 與參數的雜湊；未命中時查詢資料庫並寫入，同一查詢同時未命中只查詢一次
+// * 每列為欄位名稱對應值的 map，[]byte 轉為字串；tags 記錄於標籤集合，供 InvalidateTag 一次失效
+func (rf *RedisFallback) Query(ctx context.Context, db Querier, ttl time.Duration, tags []string, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	key, err := queryKey(query, args)
+	if err != nil {
+		return nil, newOpError(rf.logger, "query", "", TierMemory, parseError(err))
+	}
+
+	value, err := rf.GetOrSet(key, ttl, func() (interface{}, error) {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		result, err := scanRows(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range tags {
+			if _, err := rf.SAdd(queryTagPrefix+tag, key); err != nil {
+				rf.logger.Error(err, "Failed to tag query", tag)
+			}
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// * Cached rows come back from JSON as []interface{}
+	var result []map[string]interface{}
+	target := reflect.ValueOf(&result).Elem()
+	if err := rf.decodeInto(value, target, target.Type()); err != nil {
+		return nil, newOpError(rf.logger, "query", key, TierMemory, parseError(err))
+	}
+	return result, nil
+}
+
+// * 刪除標籤下所有查詢的快取與標籤本身，回傳刪除的查詢數
+func (rf *RedisFallback) InvalidateTag(tag string) (int, error) {
+	tagKey := queryTagPrefix + tag
+	keys, err := rf.SMembers(tagKey)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, key := range keys {
+		if err := rf.Del(key); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rf.Del(tagKey)
+}
+
+// * 參數以 JSON 序列化，不同型別但序列化結果相同的參數視為同一查詢
+func queryKey(query string, args []interface{}) (string, error) {
+	data, err := json.Marshal(append([]interface{}{query}, args...))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%x", queryKeyPrefix, sha256.Sum256(data)), nil
+}
+
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			// * Drivers return text columns as []byte, which JSON would encode as base64
+			if data, ok := values[i].([]byte); ok {
+				row[column] = string(data)
+				continue
+			}
+			row[column] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
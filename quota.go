@@ -0,0 +1,100 @@
+package redisFallback
+
+import (
+	"fmt"
+)
+
+const (
+	QuotaReject = "reject" // 超過配額時拒絕寫入，回傳 ErrQuotaExceeded
+	QuotaEvict  = "evict"  // 超過金鑰數或大小時將該命名空間最舊的金鑰移出記憶體層，值仍保留於本地檔案
+)
+
+// * 命名空間在降級期間的配額，0 代表不限制
+type Quota struct {
+	MaxKeys          int64  // 記憶體層的金鑰數上限
+	MaxBytes         int64  // 記憶體層的大小上限（位元組）
+	MaxOfflineWrites int64  // 單次降級期間的寫入數上限，超過時一律拒絕
+	Policy           string // 超過 MaxKeys / MaxBytes 時：reject / evict，預設 reject
+}
+
+// * 寫入本地前檢查所屬命名空間的配額，通過時計入該命名空間的降級寫入數
+func (rf *RedisFallback) checkQuota(key string, item Cache) error {
+	counter := rf.namespaces.lookup(key)
+	if counter == nil || counter.quota == nil {
+		return nil
+	}
+	quota := counter.quota
+
+	if quota.MaxOfflineWrites > 0 && counter.offlineWrites.Load() >= quota.MaxOfflineWrites {
+		counter.rejected.Add(1)
+		return newOpError(rf.logger, "set", key, TierMemory, fmt.Errorf("%w: %d offline writes", ErrQuotaExceeded, quota.MaxOfflineWrites))
+	}
+
+	// * Only the growth counts, overwriting a key with a smaller value always fits
+	keys := int64(1)
+	size := estimateSize(rf.config.Options.Encoder, key, item)
+	if old, ok := rf.sizes.Load(key); ok {
+		keys = 0
+		size -= old.(int64)
+	}
+
+	for rf.overQuota(counter, keys, size) {
+		if quota.Policy != QuotaEvict || !rf.evictOldest(counter, key) {
+			counter.rejected.Add(1)
+			return newOpError(rf.logger, "set", key, TierMemory, fmt.Errorf("%w: %d keys, %d bytes", ErrQuotaExceeded, counter.memoryKeys.Load(), counter.memoryBytes.Load()))
+		}
+	}
+
+	counter.offlineWrites.Add(1)
+	return nil
+}
+
+func (rf *RedisFallback) overQuota(counter *namespaceCounter, keys int64, size int64) bool {
+	quota := counter.quota
+	if quota.MaxKeys > 0 && keys > 0 && counter.memoryKeys.Load()+keys > quota.MaxKeys {
+		return true
+	}
+	return quota.MaxBytes > 0 && size > 0 && counter.memoryBytes.Load()+size > quota.MaxBytes
+}
+
+// * 將命名空間中最舊的金鑰寫入檔案後移出記憶體層，skip 為正在寫入的金鑰；沒有可移除的金鑰時回傳 false
+func (rf *RedisFallback) evictOldest(counter *namespaceCounter, skip string) bool {
+	var oldest string
+	var timestamp int64
+	found := false
+	rf.cache.Range(func(key, value interface{}) bool {
+		k := key.(string)
+		if k == skip || rf.namespaces.lookup(k) != counter {
+			return true
+		}
+		if v := value.(Cache); !found || v.Timestamp < timestamp {
+			oldest, timestamp, found = k, v.Timestamp, true
+		}
+		return true
+	})
+	if !found {
+		return false
+	}
+
+	// * Still queued, the file must hold the value before memory lets go of it
+	if req, ok := rf.writer.pop(oldest); ok {
+		if err := rf.writer.writeToFile(oldest, req.Data.(Cache)); err != nil {
+			rf.writer.push(req)
+			rf.logger.Error(err, "Failed to write evicted key")
+			return false
+		}
+	}
+	rf.deleteCache(oldest)
+	counter.evicted.Add(1)
+	return true
+}
+
+// * 降級結束後重新計算每個命名空間的降級寫入數
+func (n *namespaces) resetOffline() {
+	if n == nil {
+		return
+	}
+	for _, counter := range n.counters {
+		counter.offlineWrites.Store(0)
+	}
+}
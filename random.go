@@ -0,0 +1,84 @@
+package redisFallback
+
+import (
+	"context"
+	"math/rand/v2"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// * 隨機取得最多 n 個不重複的金鑰，供內容稽核與一致性抽查
+// * 正常模式使用 RANDOMKEY，降級或 Redis 失敗時從記憶體與本地檔案索引以蓄水池抽樣
+func (rf *RedisFallback) RandomKeys(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	if rf.isHealthy() {
+		keys, err := rf.randomKeysFromRedis(n)
+		if err == nil {
+			return keys
+		}
+		rf.logger.Error(err, "Failed to sample keys from Redis")
+	}
+	return rf.randomKeysFromLocal(n)
+}
+
+// * RANDOMKEY 可能重複，金鑰數少於 n 時回傳較少的金鑰
+func (rf *RedisFallback) randomKeysFromRedis(n int) ([]string, error) {
+	ctx := context.Background()
+
+	pipe := rf.redis.Pipeline()
+	cmds := make([]*redis.StringCmd, n)
+	for i := range cmds {
+		cmds[i] = pipe.RandomKey(ctx)
+	}
+	pipe.Exec(ctx)
+
+	seen := make(map[string]struct{}, n)
+	keys := make([]string, 0, n)
+	for _, cmd := range cmds {
+		key, err := cmd.Result()
+		// * Empty database
+		if err == redis.Nil {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (rf *RedisFallback) randomKeysFromLocal(n int) []string {
+	rf.index.load(rf.config, rf.marshalers)
+
+	keys := make([]string, 0, n)
+	count := 0
+	sample := func(key string) {
+		count++
+		if len(keys) < n {
+			keys = append(keys, key)
+		} else if i := rand.IntN(count); i < n {
+			keys[i] = key
+		}
+	}
+
+	rf.cache.Range(func(key, value interface{}) bool {
+		sample(key.(string))
+		return true
+	})
+	// * Files of keys already in memory are counted once
+	rf.index.keys.Range(func(key, value interface{}) bool {
+		if _, ok := rf.cache.Load(key); !ok {
+			sample(key.(string))
+		}
+		return true
+	})
+	return keys
+}
@@ -0,0 +1,539 @@
+package redisFallback
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	rdbOpSlotInfo     = 0xF4
+	rdbOpFunction2    = 0xF5
+	rdbOpFunctionPre  = 0xF6
+	rdbOpModuleAux    = 0xF7
+	rdbOpIdle         = 0xF8
+	rdbOpFreq         = 0xF9
+	rdbOpAux          = 0xFA
+	rdbOpResizeDB     = 0xFB
+	rdbOpExpireTimeMs = 0xFC
+	rdbOpExpireTime   = 0xFD
+	rdbOpSelectDB     = 0xFE
+	rdbOpEOF          = 0xFF
+)
+
+// * Redis 預設的 proto-max-bulk-len，檔案記錄的長度超過時視為格式錯誤
+const maxRDBString = 512 * 1024 * 1024
+
+const (
+	rdbTypeModule2       = 7
+	rdbTypeStream        = 15
+	rdbTypeStream2       = 19
+	rdbTypeStream3       = 21
+	rdbModuleOpcodeEOF   = 0
+	rdbModuleOpcodeFloat = 3
+	rdbModuleOpcodeDbl   = 4
+	rdbModuleOpcodeStr   = 5
+)
+
+var errRDBFormat = errors.New("Invalid RDB file")
+
+// * RDB 中的字串金鑰，其他型別略過
+type rdbEntry struct {
+	db        int
+	key       string
+	value     string
+	expireAt  int64 // 到期時間（毫秒），0 代表不過期
+	valueType byte  // 略過的 stream 與 module 值回報原本的型別
+}
+
+type rdbReader struct {
+	r *bufio.Reader
+}
+
+// * 逐一讀取 RDB 的字串金鑰，略過的 stream 與 module 值交給 skipped
+func readRDB(r io.Reader, fn func(rdbEntry) error, skipped func(rdbEntry)) error {
+	reader := &rdbReader{r: bufio.NewReader(r)}
+
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(reader.r, header); err != nil {
+		return err
+	}
+	if string(header[0:5]) != "REDIS" {
+		return errRDBFormat
+	}
+
+	db := 0
+	var expireAt int64
+	for {
+		op, err := reader.r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case rdbOpEOF:
+			return nil
+		case rdbOpSelectDB:
+			n, err := reader.length()
+			if err != nil {
+				return err
+			}
+			db = int(n)
+		case rdbOpResizeDB:
+			if err := reader.skipLengths(2); err != nil {
+				return err
+			}
+		case rdbOpSlotInfo:
+			if err := reader.skipLengths(3); err != nil {
+				return err
+			}
+		case rdbOpAux:
+			if err := reader.skipStrings(2); err != nil {
+				return err
+			}
+		case rdbOpFunction2:
+			if err := reader.skipStrings(1); err != nil {
+				return err
+			}
+		case rdbOpIdle:
+			if err := reader.skipLengths(1); err != nil {
+				return err
+			}
+		case rdbOpFreq:
+			if _, err := reader.r.ReadByte(); err != nil {
+				return err
+			}
+		case rdbOpExpireTime:
+			var sec uint32
+			if err := binary.Read(reader.r, binary.LittleEndian, &sec); err != nil {
+				return err
+			}
+			expireAt = int64(sec) * 1000
+		case rdbOpExpireTimeMs:
+			var ms uint64
+			if err := binary.Read(reader.r, binary.LittleEndian, &ms); err != nil {
+				return err
+			}
+			expireAt = int64(ms)
+		case rdbOpModuleAux:
+			// * module id, when opcode, when
+			if err := reader.skipLengths(3); err != nil {
+				return err
+			}
+			if err := reader.skipModuleValues(); err != nil {
+				return err
+			}
+		case rdbOpFunctionPre:
+			return fmt.Errorf("%w: opcode %#x is not supported", errRDBFormat, op)
+		default:
+			key, err := reader.string()
+			if err != nil {
+				return err
+			}
+			if op != 0 {
+				// * Only plain strings map onto a fallback entry
+				if err := reader.skipValue(op); err != nil {
+					return err
+				}
+				if skipped != nil && isReportedRDBType(op) {
+					skipped(rdbEntry{db: db, key: key, expireAt: expireAt, valueType: op})
+				}
+				expireAt = 0
+				continue
+			}
+
+			value, err := reader.string()
+			if err != nil {
+				return err
+			}
+			if err := fn(rdbEntry{db: db, key: key, value: value, expireAt: expireAt}); err != nil {
+				return err
+			}
+			expireAt = 0
+		}
+	}
+}
+
+// * 回傳長度；特殊編碼時 encoded 為 true，n 為編碼種類
+func (r *rdbReader) lengthEncoded() (uint64, bool, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3f), false, nil
+	case 1:
+		next, err := r.r.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b&0x3f)<<8 | uint64(next), false, nil
+	case 2:
+		if b == 0x80 {
+			var n uint32
+			err := binary.Read(r.r, binary.BigEndian, &n)
+			return uint64(n), false, err
+		}
+		if b == 0x81 {
+			var n uint64
+			err := binary.Read(r.r, binary.BigEndian, &n)
+			return n, false, err
+		}
+		return 0, false, errRDBFormat
+	default:
+		return uint64(b & 0x3f), true, nil
+	}
+}
+
+func (r *rdbReader) length() (uint64, error) {
+	n, encoded, err := r.lengthEncoded()
+	if err == nil && encoded {
+		err = errRDBFormat
+	}
+	return n, err
+}
+
+func (r *rdbReader) string() (string, error) {
+	n, encoded, err := r.lengthEncoded()
+	if err != nil {
+		return "", err
+	}
+
+	if !encoded {
+		data, err := r.bytes(n)
+		return string(data), err
+	}
+
+	switch n {
+	case 0:
+		b, err := r.r.ReadByte()
+		return strconv.Itoa(int(int8(b))), err
+	case 1:
+		var v int16
+		err := binary.Read(r.r, binary.LittleEndian, &v)
+		return strconv.Itoa(int(v)), err
+	case 2:
+		var v int32
+		err := binary.Read(r.r, binary.LittleEndian, &v)
+		return strconv.Itoa(int(v)), err
+	case 3:
+		compressed, err := r.length()
+		if err != nil {
+			return "", err
+		}
+		size, err := r.length()
+		if err != nil {
+			return "", err
+		}
+		if size > maxRDBString {
+			return "", errRDBFormat
+		}
+		data, err := r.bytes(compressed)
+		if err != nil {
+			return "", err
+		}
+		out, err := lzfDecompress(data, int(size))
+		return string(out), err
+	default:
+		return "", errRDBFormat
+	}
+}
+
+// * 長度來自檔案，不預先配置；截斷的檔案在讀到結尾時回傳錯誤，不會依記錄的長度配置記憶體
+func (r *rdbReader) bytes(n uint64) ([]byte, error) {
+	if n > maxRDBString {
+		return nil, errRDBFormat
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *rdbReader) skipLengths(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := r.length(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *rdbReader) skipStrings(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := r.string(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * 略過非字串型別的值
+func (r *rdbReader) skipValue(valueType byte) error {
+	switch valueType {
+	// * list, set
+	case 1, 2:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		return r.skipStrings(int(n))
+	// * hash
+	case 4:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		return r.skipStrings(int(n) * 2)
+	// * zset with string scores
+	case 3:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if _, err := r.string(); err != nil {
+				return err
+			}
+			size, err := r.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			// * 253: nan, 254: +inf, 255: -inf
+			if size < 253 {
+				if _, err := r.r.Discard(int(size)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	// * zset with binary scores
+	case 5:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if _, err := r.string(); err != nil {
+				return err
+			}
+			if _, err := r.r.Discard(8); err != nil {
+				return err
+			}
+		}
+		return nil
+	// * encoded as a single blob: zipmap, ziplist, intset, listpack
+	case 9, 10, 11, 12, 13, 16, 17, 20:
+		return r.skipStrings(1)
+	// * quicklist
+	case 14:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		return r.skipStrings(int(n))
+	// * module with opcode-tagged values
+	case rdbTypeModule2:
+		if _, err := r.length(); err != nil {
+			return err
+		}
+		return r.skipModuleValues()
+	case rdbTypeStream, rdbTypeStream2, rdbTypeStream3:
+		return r.skipStream(valueType)
+	// * quicklist with container type
+	case 18:
+		n, err := r.length()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if _, err := r.length(); err != nil {
+				return err
+			}
+			if _, err := r.string(); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: value type %d is not supported", errRDBFormat, valueType)
+	}
+}
+
+// * stream 與 module 值沒有對應的本地格式，回報給呼叫端；其他型別照常略過
+func isReportedRDBType(valueType byte) bool {
+	switch valueType {
+	case rdbTypeModule2, rdbTypeStream, rdbTypeStream2, rdbTypeStream3:
+		return true
+	}
+	return false
+}
+
+// * module 值以操作碼標記每個欄位，直到 EOF 操作碼
+func (r *rdbReader) skipModuleValues() error {
+	for {
+		opcode, err := r.length()
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case rdbModuleOpcodeEOF:
+			return nil
+		case rdbModuleOpcodeFloat:
+			if _, err := r.r.Discard(4); err != nil {
+				return err
+			}
+		case rdbModuleOpcodeDbl:
+			if _, err := r.r.Discard(8); err != nil {
+				return err
+			}
+		case rdbModuleOpcodeStr:
+			if err := r.skipStrings(1); err != nil {
+				return err
+			}
+		// * signed and unsigned integers
+		default:
+			if opcode > rdbModuleOpcodeStr {
+				return errRDBFormat
+			}
+			if _, err := r.length(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// * 依 Redis 的 stream 格式略過：listpack、中繼資料、消費者群組與其 PEL
+func (r *rdbReader) skipStream(valueType byte) error {
+	n, err := r.length()
+	if err != nil {
+		return err
+	}
+	// * master id and listpack per node
+	if err := r.skipStrings(int(n) * 2); err != nil {
+		return err
+	}
+
+	// * length, last id; since v2 also first id, max deleted id and entries added
+	fields := 3
+	if valueType >= rdbTypeStream2 {
+		fields += 5
+	}
+	if err := r.skipLengths(fields); err != nil {
+		return err
+	}
+
+	groups, err := r.length()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < groups; i++ {
+		if err := r.skipStrings(1); err != nil {
+			return err
+		}
+		// * last id; since v2 also entries read
+		fields := 2
+		if valueType >= rdbTypeStream2 {
+			fields++
+		}
+		if err := r.skipLengths(fields); err != nil {
+			return err
+		}
+
+		pending, err := r.length()
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < pending; j++ {
+			// * raw 128-bit id and millisecond delivery time
+			if _, err := r.r.Discard(16 + 8); err != nil {
+				return err
+			}
+			if _, err := r.length(); err != nil {
+				return err
+			}
+		}
+
+		consumers, err := r.length()
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < consumers; j++ {
+			if err := r.skipStrings(1); err != nil {
+				return err
+			}
+			// * seen time; since v3 also active time
+			times := 8
+			if valueType >= rdbTypeStream3 {
+				times += 8
+			}
+			if _, err := r.r.Discard(times); err != nil {
+				return err
+			}
+			owned, err := r.length()
+			if err != nil {
+				return err
+			}
+			if _, err := r.r.Discard(int(owned) * 16); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func lzfDecompress(in []byte, size int) ([]byte, error) {
+	// * size comes from the file, let append grow past the guess
+	out := make([]byte, 0, min(size, len(in)*2))
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+
+		// * literal run
+		if ctrl < 32 {
+			ctrl++
+			if i+ctrl > len(in) {
+				return nil, errRDBFormat
+			}
+			out = append(out, in[i:i+ctrl]...)
+			i += ctrl
+			continue
+		}
+
+		// * back reference
+		length := ctrl >> 5
+		if length == 7 {
+			if i >= len(in) {
+				return nil, errRDBFormat
+			}
+			length += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, errRDBFormat
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 {
+			return nil, errRDBFormat
+		}
+		for j := 0; j < length+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != size {
+		return nil, errRDBFormat
+	}
+	return out, nil
+}
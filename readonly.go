@@ -0,0 +1,55 @@
+package redisFallback
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const readOnlyProbeKey = "redis-fallback:probe" // 檢查是否恢復可寫入的探測金鑰
+
+// * 主節點拒絕寫入（故障轉移中）的錯誤
+func isReadOnlyError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "READONLY")
+}
+
+// * 開啟 ReadOnlyDegrade 時重試不會成功，由 redisFailed 切換至唯讀模式
+func (rf *RedisFallback) rejectsWrites(err error) bool {
+	return isReadOnlyError(err) && rf.config.Options.ReadOnlyDegrade
+}
+
+// * 讀取仍走 Redis，寫入改存本地佇列，直到 Redis 恢復可寫入
+func (rf *RedisFallback) changeToReadOnlyMode() {
+	if !rf.isReadOnly.CompareAndSwap(false, true) {
+		return
+	}
+	rf.logger.Info("Redis rejects writes, starting read-only mode")
+
+	ticker := rf.config.Options.Clock.NewTicker(rf.config.Options.TimeToCheck)
+	rf.goroutine(func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rf.closed:
+				return
+			case <-ticker.C():
+				err := rf.redis.Set(context.Background(), readOnlyProbeKey, 1, time.Second).Err()
+				if isReadOnlyError(err) {
+					continue
+				}
+				if err != nil {
+					// * Redis is down, the fallback checker takes over
+					rf.isReadOnly.Store(false)
+					return
+				}
+
+				rf.logger.Info("Redis accepts writes again, syncing spooled data")
+				rf.mutex.Lock()
+				rf.changeToNormalMode("writes accepted")
+				rf.mutex.Unlock()
+				rf.isReadOnly.Store(false)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package redisFallback
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RecoveryTTLLonger  = "longer"  // 金鑰已存在於 Redis 時保留較長的存活時間
+	RecoveryTTLShorter = "shorter" // 金鑰已存在於 Redis 時保留較短的存活時間
+)
+
+type syncItem struct {
+	key  string
+	item Cache
+}
+
+// * 復原時比對 Redis 既有金鑰的存活時間，依 RecoveryTTL 調整本地值的 TTL
+func (rf *RedisFallback) reconcileTTL(ctx context.Context, batch []syncItem) {
+	mode := rf.config.Options.RecoveryTTL
+	if mode != RecoveryTTLLonger && mode != RecoveryTTLShorter {
+		return
+	}
+
+	pipe := rf.redis.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(batch))
+	for i, s := range batch {
+		cmds[i] = pipe.PTTL(ctx, s.key)
+	}
+	pipe.Exec(ctx)
+
+	now := rf.now()
+	for i, cmd := range cmds {
+		remote, err := cmd.Result()
+		// * -2: key does not exist in Redis, keep the local TTL
+		if err != nil || remote == -2 {
+			continue
+		}
+
+		// * -1: no expiration on either side
+		local := remainingTTL(batch[i].item, now)
+		if local == 0 {
+			local = -1
+		}
+
+		batch[i].item = applyRemainingTTL(batch[i].item, pickTTL(mode, local, remote), now)
+	}
+}
+
+// * -1 代表不過期，視為最長
+func pickTTL(mode string, local time.Duration, remote time.Duration) time.Duration {
+	longer := local == -1 || (remote != -1 && local > remote)
+	if mode == RecoveryTTLLonger {
+		if longer {
+			return local
+		}
+		return remote
+	}
+	if longer {
+		return remote
+	}
+	return local
+}
@@ -0,0 +1,26 @@
+package redisFallback
+
+import (
+	"reflect"
+)
+
+// * Redis 讀到的值較新或不同時，更新記憶體與本地檔案，避免降級後讀到舊資料
+func (rf *RedisFallback) repairLocal(key string, item Cache) {
+	if cached, ok := rf.cache.Load(key); ok {
+		local := cached.(Cache)
+		if local.Timestamp > item.Timestamp || (local.Timestamp == item.Timestamp && reflect.DeepEqual(local.Data, item.Data)) {
+			return
+		}
+	}
+
+	if !rf.config.Options.DisableMirror {
+		rf.storeCache(key, item)
+	}
+
+	// * Only rewrite keys that may have a local file
+	if !rf.bloom.hasKey(key) {
+		return
+	}
+
+	rf.enqueueWrite(WriteRequest{Key: key, Data: item})
+}
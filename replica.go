@@ -0,0 +1,146 @@
+package redisFallback
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// * 待複製到 ReplicaPath 的寫入，同一路徑只保留最新的內容，不會因排隊過多而略過
+type replicaQueue struct {
+	mutex   sync.Mutex
+	pending map[string][]byte // 路徑 -> 內容，nil 時移除檔案
+	order   []string
+	clears  []string // 復原完成，先清空的資料庫目錄
+	kick    chan struct{}
+}
+
+func newReplicaQueue() *replicaQueue {
+	return &replicaQueue{
+		pending: make(map[string][]byte),
+		kick:    make(chan struct{}, 1),
+	}
+}
+
+// * 主要目錄寫入成功後才排入，依序寫入 ReplicaPath；只加入佇列，不拖慢主要寫入
+func (w *Writer) replicate(path string, data []byte) {
+	if w.replica == nil {
+		return
+	}
+	w.replica.push(replicaPath(w.config, path), data)
+}
+
+// * 移除與寫入使用同一佇列，確保順序一致；不會阻塞，可在持有 folderMutex 時呼叫
+func (w *Writer) replicaRemove(path string) {
+	if w.replica == nil {
+		return
+	}
+	w.replica.push(replicaPath(w.config, path), nil)
+}
+
+// * 清空前排入同一 DB 的寫入都會被移除，直接捨棄；Multi 的其他 DB 共用佇列，不受影響
+func (w *Writer) replicaClear() {
+	if w.replica == nil {
+		return
+	}
+	folder := replicaFolder(w.config)
+	q := w.replica
+	q.mutex.Lock()
+	q.clears = append(q.clears, folder)
+	for path := range q.pending {
+		if strings.HasPrefix(path, folder+string(filepath.Separator)) {
+			delete(q.pending, path)
+		}
+	}
+	q.mutex.Unlock()
+	q.signal()
+}
+
+func (q *replicaQueue) push(path string, data []byte) {
+	q.mutex.Lock()
+	if _, ok := q.pending[path]; !ok {
+		q.order = append(q.order, path)
+	}
+	q.pending[path] = data
+	q.mutex.Unlock()
+	q.signal()
+}
+
+func (q *replicaQueue) signal() {
+	select {
+	case q.kick <- struct{}{}:
+	default:
+	}
+}
+
+// * 關閉時寫完佇列中剩下的內容後結束
+func (w *Writer) startReplica(closed <-chan struct{}) {
+	for {
+		select {
+		case <-closed:
+			w.drainReplica()
+			return
+		case <-w.replica.kick:
+			w.drainReplica()
+		}
+	}
+}
+
+func (w *Writer) drainReplica() {
+	q := w.replica
+	for {
+		q.mutex.Lock()
+		clears := q.clears
+		order := q.order
+		pending := q.pending
+		q.clears = nil
+		q.order = nil
+		q.pending = make(map[string][]byte)
+		q.mutex.Unlock()
+
+		if len(clears) == 0 && len(order) == 0 {
+			return
+		}
+		for _, folder := range clears {
+			if err := os.RemoveAll(folder); err != nil {
+				w.logger.Error(err, "Failed to clear replica")
+			}
+		}
+		for _, path := range order {
+			// * Discarded by a later clear, or queued again after it and already written
+			if data, ok := pending[path]; ok {
+				w.writeReplica(path, data)
+				delete(pending, path)
+			}
+		}
+	}
+}
+
+func (w *Writer) writeReplica(path string, data []byte) {
+	if data == nil {
+		os.Remove(path)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), w.config.Options.DirMode); err != nil {
+		w.logger.Error(err, "Failed to create replica folder")
+		return
+	}
+	if err := os.WriteFile(path, data, w.config.Options.FileMode); err != nil {
+		w.logger.Error(err, "Failed to write replica")
+	}
+}
+
+// * 與主要目錄相同的相對路徑，位於 {ReplicaPath}/{db} 之下
+func replicaPath(config Config, path string) string {
+	rel, err := filepath.Rel(config.Options.DBPath, path)
+	if err != nil {
+		return filepath.Join(config.Options.ReplicaPath, filepath.Base(path))
+	}
+	return filepath.Join(config.Options.ReplicaPath, rel)
+}
+
+func replicaFolder(config Config) string {
+	return filepath.Join(config.Options.ReplicaPath, strconv.Itoa(config.Redis.DB))
+}
@@ -0,0 +1,195 @@
+package redisFallback
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// * 將本地資料輸出為 RESP SET 指令，可直接以 redis-cli --pipe 匯入任一 Redis，回傳輸出筆數
+func (rf *RedisFallback) ExportRESP(w io.Writer) (int, error) {
+	now := rf.now()
+	items := make(map[string]Cache)
+
+	rf.writer.checkpoint()
+	folderPath := filepath.Join(rf.config.Options.DBPath, strconv.Itoa(rf.config.Redis.DB))
+	filepath.WalkDir(folderPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		if item, err := decodeCache(rf.config, rf.marshalers, data); err == nil {
+			items[item.Key] = item
+		}
+		return nil
+	})
+
+	// * Memory holds writes not flushed to disk yet
+	rf.cache.Range(func(key, value interface{}) bool {
+		item := value.(Cache)
+		if file, ok := items[key.(string)]; !ok || item.Timestamp >= file.Timestamp {
+			items[key.(string)] = item
+		}
+		return true
+	})
+
+	buf := bufio.NewWriter(w)
+	count := 0
+	for key, item := range items {
+		if isExpired(item, now) {
+			continue
+		}
+
+		commands, err := rf.respCommands(key, item)
+		if err != nil {
+			rf.logger.Error(err, "Failed to parse")
+			continue
+		}
+		for _, args := range commands {
+			if err := writeRESP(buf, args); err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+
+	// * Messages published while Redis was down are kept apart from keys
+	for channel, messages := range rf.bufferedMessages() {
+		for _, message := range messages {
+			if err := writeRESP(buf, []string{"PUBLISH", channel, message}); err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+
+	return count, buf.Flush()
+}
+
+// * 雜湊輸出 HDEL、HSET 與 EXPIREAT，集合輸出 SREM、SADD 與 EXPIREAT，有序集合輸出 ZADD，清單輸出 LPUSH / RPUSH，刪除紀錄輸出 DEL，其餘輸出 SET ... EXAT
+func (rf *RedisFallback) respCommands(key string, item Cache) ([][]string, error) {
+	expireAt := strconv.FormatInt(item.Timestamp+item.TTL, 10)
+
+	if item.Type == tombstoneType {
+		return [][]string{{"DEL", key}}, nil
+	}
+
+	if item.Type == listType {
+		list, _ := item.Data.([]interface{})
+		head := min(item.Head, len(list))
+		var commands [][]string
+		if head > 0 {
+			args, err := rf.listArgs(list[:head])
+			if err != nil {
+				return nil, err
+			}
+			// * Reversed so LPUSH restores the original order
+			cmd := []string{"LPUSH", key}
+			for i := len(args) - 1; i >= 0; i-- {
+				cmd = append(cmd, args[i].(string))
+			}
+			commands = append(commands, cmd)
+		}
+		if head < len(list) {
+			args, err := rf.listArgs(list[head:])
+			if err != nil {
+				return nil, err
+			}
+			cmd := []string{"RPUSH", key}
+			for _, arg := range args {
+				cmd = append(cmd, arg.(string))
+			}
+			commands = append(commands, cmd)
+		}
+		return commands, nil
+	}
+
+	if item.Type == setType {
+		var commands [][]string
+		if len(item.Removed) > 0 {
+			commands = append(commands, append([]string{"SREM", key}, item.Removed...))
+		}
+		if set := setMembers(item); len(set) > 0 {
+			commands = append(commands, append([]string{"SADD", key}, setList(set)...))
+		}
+		if item.TTL > 0 && len(commands) > 0 {
+			commands = append(commands, []string{"EXPIREAT", key, expireAt})
+		}
+		return commands, nil
+	}
+
+	if item.Type == zsetType {
+		args := []string{"ZADD", key}
+		if rf.config.Options.ZSetMerge == ZSetMergeMax {
+			args = append(args, "GT")
+		}
+		var commands [][]string
+		if scores := zsetRESP(item); len(scores) > 0 {
+			commands = append(commands, append(args, scores...))
+		}
+		for _, member := range slices.Sorted(maps.Keys(item.Increments)) {
+			commands = append(commands, []string{"ZINCRBY", key, strconv.FormatFloat(item.Increments[member], 'g', -1, 64), member})
+		}
+		if item.TTL > 0 && len(commands) > 0 {
+			commands = append(commands, []string{"EXPIREAT", key, expireAt})
+		}
+		return commands, nil
+	}
+
+	if item.Type == hashType {
+		var commands [][]string
+		if len(item.Removed) > 0 {
+			commands = append(commands, append([]string{"HDEL", key}, item.Removed...))
+		}
+		if fields, _ := item.Data.(map[string]interface{}); len(fields) > 0 || len(commands) == 0 {
+			fields, err := rf.hashArgs(item)
+			if err != nil {
+				return nil, err
+			}
+			args := []string{"HSET", key}
+			for _, field := range fields {
+				args = append(args, field.(string))
+			}
+			commands = append(commands, args)
+		}
+		if item.TTL > 0 {
+			commands = append(commands, []string{"EXPIREAT", key, expireAt})
+		}
+		return commands, nil
+	}
+
+	data, err := rf.marshalCache(item)
+	if err != nil {
+		return nil, err
+	}
+	// * Counters are raw integers in Redis so INCR keeps working
+	if value, ok := counterValue(item); ok {
+		data = []byte(strconv.FormatInt(value, 10))
+	}
+	args := []string{"SET", key, string(data)}
+	if item.TTL > 0 {
+		args = append(args, "EXAT", expireAt)
+	}
+	return [][]string{args}, nil
+}
+
+func writeRESP(w *bufio.Writer, args []string) error {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		w.WriteString(arg)
+		if _, err := w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
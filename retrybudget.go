@@ -0,0 +1,59 @@
+package redisFallback
+
+import (
+	"sync"
+	"time"
+)
+
+// * 所有操作共用的重試額度（token bucket），Redis 不穩時避免大量 goroutine 同時重試
+type retryBudget struct {
+	mutex  sync.Mutex
+	clock  Clock
+	limit  float64 // 每秒補充的數量，同時也是上限
+	tokens float64
+	last   time.Time
+}
+
+// * limit 為 0 時回傳 nil，不限制
+func newRetryBudget(limit int, clock Clock) *retryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &retryBudget{
+		clock:  clock,
+		limit:  float64(limit),
+		tokens: float64(limit),
+		last:   clock.Now(),
+	}
+}
+
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	b.tokens = min(b.limit, b.tokens+now.Sub(b.last).Seconds()*b.limit)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// * 第一次嘗試不消耗額度；額度用完時不再重試，由呼叫端直接切換至降級模式
+func (rf *RedisFallback) canRetry(i int) bool {
+	if i >= rf.config.Options.MaxRetry {
+		return false
+	}
+	if i == 0 || rf.retryBudget.take() {
+		return true
+	}
+	rf.metrics.throttled.Add(1)
+	return false
+}
@@ -0,0 +1,138 @@
+package redisFallback
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Ring struct {
+	Addrs    map[string]string `json:"addrs"`              // 節點名稱對應位址
+	Username string            `json:"username,omitempty"` // Redis ACL 使用者，預設 default
+	Password string            `json:"password,omitempty"` // Redis 密碼，可用 UpdateCredentials 輪替
+	DB       int               `json:"db"`                 // Redis 資料庫編號
+}
+
+// * 各節點的健康狀態
+type nodeHealth struct {
+	mutex sync.RWMutex
+	list  map[string]bool
+}
+
+func initRing(c Config, creds *credentials, nodes *nodeHealth) *redis.Ring {
+	names := make(map[string]string, len(c.Ring.Addrs))
+	for name, addr := range c.Ring.Addrs {
+		names[addr] = name
+	}
+	return redis.NewRing(&redis.RingOptions{
+		Addrs: c.Ring.Addrs,
+		DB:    c.Ring.DB,
+		// * Every shard reads the current credentials when dialing
+		NewClient: func(opt *redis.Options) *redis.Client {
+			opt.CredentialsProvider = creds.get
+			client := redis.NewClient(opt)
+			client.AddHook(nodeHook{nodes: nodes, name: names[opt.Addr]})
+			return client
+		},
+	})
+}
+
+func (n *nodeHealth) set(name string, healthy bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.list == nil {
+		n.list = make(map[string]bool)
+	}
+	n.list[name] = healthy
+}
+
+// * 每個節點的指令結果即時更新 NodeHealth，正常模式與自訂 Prober 時不需等待 ping
+type nodeHook struct {
+	nodes *nodeHealth
+	name  string
+}
+
+func (h nodeHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		h.observe(ctx, err)
+		return conn, err
+	}
+}
+
+func (h nodeHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		h.observe(ctx, err)
+		return err
+	}
+}
+
+func (h nodeHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			h.observe(ctx, cmd.Err())
+		}
+		return err
+	}
+}
+
+func (h nodeHook) observe(ctx context.Context, err error) {
+	// * Caller gave up, says nothing about the node
+	if ctx.Err() != nil {
+		return
+	}
+	h.nodes.set(h.name, !isUnavailable(err))
+}
+
+// * 單機直接 ping；Ring 逐一 ping 各節點，至少一個節點可用即視為可用
+func (rf *RedisFallback) ping(ctx context.Context) error {
+	ring, ok := rf.redis.(*redis.Ring)
+	if !ok {
+		return rf.redis.Ping(ctx).Err()
+	}
+
+	// * Shards marked down by the ring are skipped by ForEachShard, default to unhealthy
+	names := make(map[string]string, len(rf.config.Ring.Addrs))
+	list := make(map[string]bool, len(rf.config.Ring.Addrs))
+	for name, addr := range rf.config.Ring.Addrs {
+		names[addr] = name
+		list[name] = false
+	}
+
+	var mutex sync.Mutex
+	ring.ForEachShard(ctx, func(ctx context.Context, client *redis.Client) error {
+		err := client.Ping(ctx).Err()
+		mutex.Lock()
+		list[names[client.Options().Addr]] = err == nil
+		mutex.Unlock()
+		return nil
+	})
+
+	rf.nodes.mutex.Lock()
+	rf.nodes.list = list
+	rf.nodes.mutex.Unlock()
+
+	for _, healthy := range list {
+		if healthy {
+			return nil
+		}
+	}
+	return fmt.Errorf("All ring nodes are unavailable")
+}
+
+// * Ring 各節點最近一次檢查或指令的健康狀態
+func (rf *RedisFallback) NodeHealth() map[string]bool {
+	rf.nodes.mutex.RLock()
+	defer rf.nodes.mutex.RUnlock()
+
+	list := make(map[string]bool, len(rf.nodes.list))
+	for name, healthy := range rf.nodes.list {
+		list[name] = healthy
+	}
+	return list
+}
@@ -0,0 +1,169 @@
+package redisFallback
+
+import (
+	"context"
+	"sort"
+)
+
+const defaultScanCount = 10 // 與 Redis SCAN 的預設 COUNT 相同
+
+// * 以 Redis glob 格式列舉金鑰，回傳本批金鑰與下一個 cursor，cursor 為 0 時表示已結束
+// * Redis 使用 SCAN；降級時列舉記憶體層與本地檔案，cursor 為排序後的位移
+// * cursor 只在回傳它的模式下有效，模式切換後請從 0 重新開始
+func (rf *RedisFallback) Scan(pattern string, cursor uint64, count int) ([]string, uint64, error) {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var keys []string
+			var next uint64
+			keys, next, err = rf.redis.Scan(ctx, cursor, pattern, int64(count)).Result()
+			if err == nil {
+				return keys, next, nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("scan", "", err); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	keys, next := rf.scanLocal(pattern, cursor, count)
+	return keys, next, nil
+}
+
+func (rf *RedisFallback) scanLocal(pattern string, cursor uint64, count int) ([]string, uint64) {
+	list := rf.localKeys(pattern)
+	if cursor >= uint64(len(list)) {
+		return nil, 0
+	}
+
+	end := cursor + uint64(count)
+	if end >= uint64(len(list)) {
+		return list[cursor:], 0
+	}
+	return list[cursor:end], end
+}
+
+// * 記憶體層與本地檔案的金鑰合併去重後排序，確保分批列舉的順序穩定
+func (rf *RedisFallback) localKeys(pattern string) []string {
+	rf.index.load(rf.config, rf.marshalers)
+
+	seen := make(map[string]struct{})
+	now := rf.now()
+	rf.cache.Range(func(key, value interface{}) bool {
+		if !isExpired(value.(Cache), now) && matchGlob(pattern, key.(string)) {
+			seen[key.(string)] = struct{}{}
+		}
+		return true
+	})
+	rf.index.keys.Range(func(key, value interface{}) bool {
+		if matchGlob(pattern, key.(string)) {
+			seen[key.(string)] = struct{}{}
+		}
+		return true
+	})
+
+	list := make([]string, 0, len(seen))
+	for key := range seen {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// * Redis glob：* 任意字元、? 單一字元、[abc] / [^a-z] 字元集合、\ 跳脫
+func matchGlob(pattern string, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if matchGlob(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			end, ok := matchClass(pattern, str[0])
+			if !ok {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[end:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(str) == 0
+}
+
+// * 回傳字元集合結束後的位置與是否符合
+func matchClass(pattern string, c byte) (int, bool) {
+	i := 1
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+
+	matched := false
+	for ; i < len(pattern) && pattern[i] != ']'; i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+			if pattern[i] == c {
+				matched = true
+			}
+			continue
+		}
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if pattern[i] == c {
+			matched = true
+		}
+	}
+	// * Unclosed class, Redis treats the rest as the class
+	if i < len(pattern) {
+		i++
+	}
+	return i, matched != negate
+}
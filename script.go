@@ -0,0 +1,58 @@
+package redisFallback
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+var ErrScriptsUnavailable = errors.New("Scripts are unavailable in fallback mode")
+
+// * 降級時替代 Lua 腳本的本地函式
+type LocalScript func(keys []string, args ...interface{}) (interface{}, error)
+
+type scripts struct {
+	list sync.Map
+}
+
+// * 註冊腳本的本地替代函式，以腳本 SHA1 對應 Eval / EvalSha
+func (rf *RedisFallback) RegisterScript(script string, fn LocalScript) string {
+	sha := scriptSha(script)
+	rf.scripts.list.Store(sha, fn)
+	return sha
+}
+
+func (rf *RedisFallback) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if rf.isHealthy() {
+		return rf.redis.Eval(context.Background(), script, keys, args...).Result()
+	}
+	return rf.evalLocal(scriptSha(script), keys, args...)
+}
+
+func (rf *RedisFallback) EvalSha(sha string, keys []string, args ...interface{}) (interface{}, error) {
+	if rf.isHealthy() {
+		return rf.redis.EvalSha(context.Background(), sha, keys, args...).Result()
+	}
+	return rf.evalLocal(sha, keys, args...)
+}
+
+func (rf *RedisFallback) evalLocal(sha string, keys []string, args ...interface{}) (interface{}, error) {
+	fn, ok := rf.scripts.list.Load(sha)
+	if !ok {
+		return nil, newOpError(rf.logger, "eval", sha, TierMemory, ErrScriptsUnavailable)
+	}
+	return fn.(LocalScript)(keys, args...)
+}
+
+func (rf *RedisFallback) isHealthy() bool {
+	rf.mutex.RLock()
+	defer rf.mutex.RUnlock()
+	return rf.isHealth
+}
+
+func scriptSha(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
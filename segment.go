@@ -0,0 +1,320 @@
+package redisFallback
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentFile         = "segment.log"   // 每個第一層分片的附加寫入檔
+	segmentHeader       = 9               // 1 位元組操作 + 4 位元組金鑰長度 + 4 位元組資料長度
+	defaultSegmentBytes = 4 * 1024 * 1024 // segment 超過此大小時展開為金鑰檔案
+)
+
+const (
+	segmentWrite byte = 'w' // 金鑰最新的檔案內容
+	segmentDrop  byte = 'd' // 金鑰檔案已直接寫入或移除，之前的紀錄作廢
+)
+
+type segmentEntry struct {
+	path   string
+	offset int64
+	size   int
+}
+
+// * SegmentWrites 啟用時，排程寫入以一個分片一次附加取代每個金鑰一個檔案；讀取、掃描與復原前展開為金鑰檔案
+type segments struct {
+	mutex   sync.Mutex
+	entries map[string]segmentEntry // 金鑰 -> 最新紀錄的位置
+	sizes   map[string]int64        // segment 路徑 -> 大小
+}
+
+func newSegments(config Config) *segments {
+	if !config.Options.SegmentWrites {
+		return nil
+	}
+	return &segments{
+		entries: make(map[string]segmentEntry),
+		sizes:   make(map[string]int64),
+	}
+}
+
+// * 金鑰所屬的第一層分片，MD5 分片為前 2 碼，自訂路徑為第一層目錄，直接位於 {DBPath}/{db} 時為 "."
+func shardName(config Config, key string) string {
+	root := filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB))
+	rel, err := filepath.Rel(root, getPath(config, key).folderPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "."
+	}
+	return strings.Split(rel, string(filepath.Separator))[0]
+}
+
+func segmentPath(config Config, key string) string {
+	return filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB), shardName(config, key), segmentFile)
+}
+
+func appendRecord(buf *bytes.Buffer, op byte, key string, data []byte) {
+	var header [segmentHeader]byte
+	header[0] = op
+	binary.BigEndian.PutUint32(header[1:5], uint32(len(key)))
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(data)))
+	buf.Write(header[:])
+	buf.WriteString(key)
+	buf.Write(data)
+}
+
+// * 需持有 folderMutex 讀取鎖；同一分片的請求編碼後一次附加，回傳寫入的請求
+func (w *Writer) writeSegment(batch []WriteRequest) []WriteRequest {
+	path := segmentPath(w.config, batch[0].Key)
+
+	type record struct {
+		req    WriteRequest
+		offset int64
+		size   int
+		data   []byte
+	}
+	var buf bytes.Buffer
+	var records []record
+	for _, req := range batch {
+		item, ok := req.Data.(Cache)
+		if !ok {
+			continue
+		}
+		if err := w.beforeFileWrite(req.Key); err != nil {
+			w.logger.Error(err, "Failed to write file")
+			w.diskResult(err)
+			continue
+		}
+		data, err := encodeFile(w.config, w.marshalers, item)
+		if err != nil {
+			w.logger.Error(err, "Failed to parse")
+			continue
+		}
+		offset := int64(buf.Len() + segmentHeader + len(req.Key))
+		appendRecord(&buf, segmentWrite, req.Key, data)
+		records = append(records, record{req: req, offset: offset, size: len(data), data: data})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := w.ensureFolder(filepath.Dir(path)); err != nil {
+		w.logger.Error(err, "Failed to create folder")
+		w.diskResult(err)
+		return nil
+	}
+
+	w.segments.mutex.Lock()
+	defer w.segments.mutex.Unlock()
+
+	base, err := w.appendSegment(path, buf.Bytes())
+	w.diskResult(err)
+	if err != nil {
+		w.logger.Error(err, "Failed to write file")
+		return nil
+	}
+
+	written := make([]WriteRequest, 0, len(records))
+	for _, r := range records {
+		w.segments.entries[r.req.Key] = segmentEntry{path: path, offset: base + r.offset, size: r.size}
+		// * Replica keeps plain key files, recovery reads it without the segment
+		w.replicate(getPath(w.config, r.req.Key).filepath, r.data)
+		written = append(written, r.req)
+	}
+
+	if w.segments.sizes[path] > defaultSegmentBytes {
+		if err := w.materialize(path); err != nil {
+			w.logger.Error(err, "Failed to checkpoint segment")
+		}
+	}
+	return written
+}
+
+// * 需持有 segments.mutex，回傳附加前的大小
+func (w *Writer) appendSegment(path string, data []byte) (int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.config.Options.FileMode)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := file.Write(data); err != nil {
+		return 0, err
+	}
+	w.segments.sizes[path] = info.Size() + int64(len(data))
+	return info.Size(), nil
+}
+
+// * 讀取金鑰在 segment 中最新的檔案內容，不在 segment 時 ok 為 false
+func (w *Writer) readSegment(key string) ([]byte, bool, error) {
+	if w.segments == nil {
+		return nil, false, nil
+	}
+	w.segments.mutex.Lock()
+	defer w.segments.mutex.Unlock()
+
+	entry, ok := w.segments.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	file, err := os.Open(entry.path)
+	// * Checkpointed by another process (Verify), the key file has the value
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	defer file.Close()
+
+	data := make([]byte, entry.size)
+	if _, err := file.ReadAt(data, entry.offset); err != nil {
+		return nil, true, err
+	}
+	return data, true, nil
+}
+
+// * 金鑰檔案已直接寫入或移除，segment 中較舊的紀錄不得在展開時覆蓋
+func (w *Writer) dropSegment(key string) {
+	if w.segments == nil {
+		return
+	}
+	w.segments.mutex.Lock()
+	defer w.segments.mutex.Unlock()
+
+	entry, ok := w.segments.entries[key]
+	if !ok {
+		return
+	}
+	delete(w.segments.entries, key)
+
+	var buf bytes.Buffer
+	appendRecord(&buf, segmentDrop, key, nil)
+	if _, err := w.appendSegment(entry.path, buf.Bytes()); err != nil {
+		w.logger.Error(err, "Failed to write file")
+	}
+}
+
+// * 將所有 segment 展開為金鑰檔案，掃描本地檔案前呼叫
+func (w *Writer) checkpoint() {
+	if w.segments == nil {
+		return
+	}
+	w.folderMutex.RLock()
+	defer w.folderMutex.RUnlock()
+	w.segments.mutex.Lock()
+	defer w.segments.mutex.Unlock()
+
+	for path := range w.segments.sizes {
+		if err := w.materialize(path); err != nil {
+			w.logger.Error(err, "Failed to checkpoint segment")
+		}
+	}
+}
+
+// * 需持有 segments.mutex
+func (w *Writer) materialize(path string) error {
+	if _, err := materializeSegment(w.config, path); err != nil {
+		return err
+	}
+	delete(w.segments.sizes, path)
+	for key, entry := range w.segments.entries {
+		if entry.path == path {
+			delete(w.segments.entries, key)
+		}
+	}
+	return nil
+}
+
+// * 需持有 folderMutex 寫入鎖；清除本地檔案時一併移除
+func (w *Writer) clearSegments() {
+	if w.segments != nil {
+		w.segments.mutex.Lock()
+		defer w.segments.mutex.Unlock()
+		clear(w.segments.entries)
+		clear(w.segments.sizes)
+	}
+	paths, _ := segmentFiles(w.config)
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+func segmentFiles(config Config) ([]string, error) {
+	root := filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB))
+	paths, err := filepath.Glob(filepath.Join(root, "*", segmentFile))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(root, segmentFile)); err == nil {
+		paths = append(paths, filepath.Join(root, segmentFile))
+	}
+	return paths, nil
+}
+
+// * 啟動時與不建立實例的工具使用：展開上次留下的 segment，回傳寫入的檔案數
+func checkpointSegments(config Config) (int, error) {
+	paths, err := segmentFiles(config)
+	if err != nil {
+		return 0, err
+	}
+	written := 0
+	for _, path := range paths {
+		n, err := materializeSegment(config, path)
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// * 依序套用紀錄，每個金鑰只寫入最後的內容後移除 segment；結尾不完整的紀錄（寫入中斷）略過
+func materializeSegment(config Config, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	latest := make(map[string][]byte)
+	for len(data) >= segmentHeader {
+		op := data[0]
+		keySize := int(binary.BigEndian.Uint32(data[1:5]))
+		dataSize := int(binary.BigEndian.Uint32(data[5:9]))
+		if keySize > len(data)-segmentHeader || dataSize > len(data)-segmentHeader-keySize {
+			break
+		}
+		key := string(data[segmentHeader : segmentHeader+keySize])
+		value := data[segmentHeader+keySize : segmentHeader+keySize+dataSize]
+		data = data[segmentHeader+keySize+dataSize:]
+
+		if op == segmentDrop {
+			delete(latest, key)
+			continue
+		}
+		latest[key] = value
+	}
+
+	for key, value := range latest {
+		target := getPath(config, key)
+		if err := os.MkdirAll(target.folderPath, config.Options.DirMode); err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(target.filepath, value, config.Options.FileMode); err != nil {
+			return 0, err
+		}
+	}
+	return len(latest), os.Remove(path)
+}
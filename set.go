@@ -2,67 +2,158 @@ package redisFallback
 
 import (
 	"context"
-	"encoding/json"
-	"reflect"
-	"strings"
+	"errors"
 	"time"
 )
 
 func (rf *RedisFallback) Set(key string, value interface{}, ttl time.Duration) error {
+	return rf.set(context.Background(), "", PriorityNormal, key, value, ttl)
+}
+
+// * 同 Set，ctx 取消或逾時時停止重試並回傳 ctx 的錯誤，不會因此切換至降級模式
+func (rf *RedisFallback) SetCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return rf.set(ctx, "", PriorityNormal, key, value, ttl)
+}
+
+// * 以絕對時間設定到期，Redis 使用 EXPIREAT 語意
+func (rf *RedisFallback) SetAt(key string, value interface{}, expireAt time.Time) error {
+	ttl := time.Duration(expireAt.Unix()-rf.now().Unix()) * time.Second
+	// * Deadline already passed
+	if ttl <= 0 {
+		return rf.Del(key)
+	}
+	return rf.set(context.Background(), "", PriorityNormal, key, value, ttl)
+}
+
+// * 指定降級時寫入檔案的優先順序
+func (rf *RedisFallback) SetPriority(key string, value interface{}, ttl time.Duration, priority Priority) error {
+	return rf.set(context.Background(), "", priority, key, value, ttl)
+}
+
+func (rf *RedisFallback) set(ctx context.Context, actor string, priority Priority, key string, value interface{}, ttl time.Duration) error {
+	if err := rf.validateKey("set", key); err != nil {
+		return err
+	}
+	if err := rf.checkWritable("set", key); err != nil {
+		return err
+	}
+	if handled, err := rf.handleNil(ctx, actor, "set", key, value); handled {
+		return err
+	}
+
 	rf.mutex.RLock()
 	isHealth := rf.isHealth
 	rf.mutex.RUnlock()
 
+	item := rf.newCache(key, value, ttl)
+	if rf.isDuplicateSet(item) {
+		return nil
+	}
+	rf.recordSet(actor, item, isHealth)
+
+	if isHealth && !rf.isReadOnly.Load() {
+		rf.markRecoveryWrite(key)
+		return rf.setToRedis(ctx, key, item, priority)
+	}
+	return rf.setLocal(ctx, key, item, priority)
+}
+
+func (rf *RedisFallback) newCache(key string, value interface{}, ttl time.Duration) Cache {
 	item := Cache{
 		Key:       key,
 		Data:      value,
-		Type:      reflect.TypeOf(value).String(),
-		Timestamp: time.Now().Unix(),
+		Type:      typeName(value),
+		Timestamp: rf.now().Unix(),
 	}
 
 	if ttl > 0 {
 		item.TTL = int64(ttl.Seconds())
 	}
-
-	if isHealth {
-		return rf.setToRedis(key, item)
-	}
-	return rf.setToMemory(key, item)
+	return item
 }
 
-func (rf *RedisFallback) setToRedis(key string, cache Cache) error {
-	ctx := context.Background()
+func (rf *RedisFallback) recordSet(actor string, item Cache, isHealth bool) {
+	rf.metrics.sets.Add(1)
+	rf.namespaces.set(item.Key)
+	rf.auditor.record(AuditEntry{
+		Op:    "set",
+		Key:   item.Key,
+		Size:  estimateSize(rf.config.Options.Encoder, item.Key, item),
+		TTL:   item.TTL,
+		Mode:  modeName(isHealth),
+		Actor: actor,
+	})
+}
 
-	data, err := json.Marshal(cache.Data)
-	data = []byte(strings.Trim(string(data), "\""))
+func (rf *RedisFallback) setToRedis(ctx context.Context, key string, cache Cache, priority Priority) error {
+	data, err := rf.marshalCache(cache)
 	if err != nil {
-		return rf.logger.Error(err, "Failed to parse")
+		return newOpError(rf.logger, "set", key, TierRedis, parseError(err))
 	}
 
-	for i := 0; i < rf.config.Option.MaxRetry; i++ {
-		err = rf.redis.Set(ctx, key, data, time.Duration(cache.TTL)*time.Second).Err()
+	for i := 0; rf.canRetry(i); i++ {
+		err = rf.redis.SetArgs(ctx, key, data, setArgs(cache)).Err()
+		// * Redis has the value even if ctx ended right after, memory must match
 		if err == nil {
-			rf.cache.Store(key, cache)
+			if rf.config.Options.DisableMirror {
+				rf.deleteCache(key)
+			} else {
+				rf.storeCache(key, cache)
+			}
 			return nil
 		}
+		// * Caller gave up, not a Redis failure
+		if ctx.Err() != nil {
+			return ctxError("set", key, TierRedis, ctx.Err())
+		}
+		if rf.stopRetry(err) {
+			break
+		}
 	}
 
-	rf.logger.Info("[setToRedis] Switching to fallback mode")
-	rf.mutex.Lock()
-	rf.changeToFallbackMode()
-	rf.mutex.Unlock()
+	if err := rf.redisFailed("set", key, err); err != nil {
+		return err
+	}
+	return rf.setLocal(ctx, key, cache, priority)
+}
 
-	return rf.setToMemory(key, cache)
+// * Set 降級時的寫入，與同一金鑰的 SetNX / SetXX 互斥
+func (rf *RedisFallback) setLocal(ctx context.Context, key string, item Cache, priority Priority) error {
+	unlock := rf.conditional.lock(key)
+	defer unlock()
+	return rf.setToMemory(ctx, key, item, priority)
 }
 
-func (rf *RedisFallback) setToMemory(key string, item Cache) error {
-	rf.cache.Store(key, item)
+func (rf *RedisFallback) setToMemory(ctx context.Context, key string, item Cache, priority Priority) error {
+	if err := rf.checkQuota(key, item); err != nil {
+		return err
+	}
+	rf.offlineWrites.Add(1)
+	rf.markDirty(key)
+	rf.tombstones.Delete(key)
 
-	select {
-	case rf.writer.queue <- WriteRequest{Key: key, Data: item}:
-	default:
+	// * Not admitted to memory, write to file now so reads can find it
+	if !rf.storeCache(key, item) {
+		rf.writer.remove(key)
+		if ctx.Err() != nil {
+			return ctxError("set", key, TierFile, ctx.Err())
+		}
 		return rf.writer.writeToFile(key, item)
 	}
 
+	return rf.enqueueWrite(WriteRequest{Key: key, Data: item, Priority: priority})
+}
+
+func (rf *RedisFallback) enqueueWrite(req WriteRequest) error {
+	// * Queue is full, write to file directly
+	if !rf.writer.push(req) {
+		err := rf.writer.writeToFile(req.Key, req.Data.(Cache))
+		// * Memory only while the disk is down, the value is kept in memory
+		if errors.Is(err, ErrDiskUnavailable) {
+			return nil
+		}
+		return err
+	}
+
 	return nil
 }
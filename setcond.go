@@ -0,0 +1,106 @@
+package redisFallback
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const conditionalStripes = 256 // 金鑰鎖的數量，依金鑰雜湊分配
+
+// * 降級時的條件寫入需先檢查再寫入；同一金鑰的 SetNX / SetXX 與一般 Set 依序執行
+type conditional struct {
+	stripes [conditionalStripes]sync.Mutex
+}
+
+// * 回傳解鎖函式
+func (c *conditional) lock(key string) func() {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	mutex := &c.stripes[hash.Sum32()%conditionalStripes]
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// * 金鑰不存在時才寫入，回傳是否已寫入
+func (rf *RedisFallback) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return rf.setIf("NX", key, value, ttl)
+}
+
+// * 金鑰已存在時才寫入，回傳是否已寫入
+func (rf *RedisFallback) SetXX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return rf.setIf("XX", key, value, ttl)
+}
+
+// * Redis 使用 SET NX / XX；降級時依記憶體層與本地檔案判斷金鑰是否存在
+func (rf *RedisFallback) setIf(mode string, key string, value interface{}, ttl time.Duration) (bool, error) {
+	if err := rf.validateKey("set", key); err != nil {
+		return false, err
+	}
+	if err := rf.checkWritable("set", key); err != nil {
+		return false, err
+	}
+	if isNilValue(value) {
+		return false, newOpError(rf.logger, "set", key, TierMemory, ErrNilValue)
+	}
+
+	isHealth := rf.isHealthy()
+	item := rf.newCache(key, value, ttl)
+
+	if isHealth && !rf.isReadOnly.Load() {
+		data, err := rf.marshalCache(item)
+		if err != nil {
+			return false, newOpError(rf.logger, "set", key, TierRedis, parseError(err))
+		}
+
+		args := setArgs(item)
+		args.Mode = mode
+		ctx := context.Background()
+		for i := 0; rf.canRetry(i); i++ {
+			err = rf.redis.SetArgs(ctx, key, data, args).Err()
+			// * Condition not met
+			if err == redis.Nil {
+				return false, nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+			if err == nil {
+				rf.markRecoveryWrite(key)
+				rf.recordSet("", item, isHealth)
+				if rf.config.Options.DisableMirror {
+					rf.deleteCache(key)
+				} else {
+					rf.storeCache(key, item)
+				}
+				return true, nil
+			}
+		}
+
+		if err := rf.redisFailed("set", key, err); err != nil {
+			return false, err
+		}
+	}
+
+	return rf.setIfLocal(mode, isHealth, item)
+}
+
+// * 與同一金鑰的 Set、SetMany 及其他 SetNX / SetXX 互斥，檢查到寫入之間不會被覆蓋
+func (rf *RedisFallback) setIfLocal(mode string, isHealth bool, item Cache) (bool, error) {
+	unlock := rf.conditional.lock(item.Key)
+	defer unlock()
+
+	_, exists := rf.localItem(item.Key)
+	if exists != (mode == "XX") {
+		return false, nil
+	}
+
+	rf.recordSet("", item, isHealth)
+	if err := rf.setToMemory(context.Background(), item.Key, item, PriorityNormal); err != nil {
+		return false, err
+	}
+	return true, nil
+}
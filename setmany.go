@@ -0,0 +1,164 @@
+package redisFallback
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Item struct {
+	Key   string
+	Value interface{}
+	TTL   time.Duration
+}
+
+type setManyItem struct {
+	item Cache
+	data []byte
+}
+
+// * 批次寫入，Redis 以單一 pipeline 寫入，降級時合併為一次檔案寫入；回傳失敗的金鑰與錯誤
+func (rf *RedisFallback) SetMany(items []Item) map[string]error {
+	errs := make(map[string]error)
+	isHealth := rf.isHealthy()
+
+	list := make([]Cache, 0, len(items))
+	for _, it := range items {
+		if err := rf.validateKey("set", it.Key); err != nil {
+			errs[it.Key] = err
+			continue
+		}
+		if err := rf.checkWritable("set", it.Key); err != nil {
+			errs[it.Key] = err
+			continue
+		}
+		if handled, err := rf.handleNil(context.Background(), "", "set", it.Key, it.Value); handled {
+			if err != nil {
+				errs[it.Key] = err
+			}
+			continue
+		}
+		item := rf.newCache(it.Key, it.Value, it.TTL)
+		if rf.isDuplicateSet(item) {
+			continue
+		}
+		rf.recordSet("", item, isHealth)
+		list = append(list, item)
+	}
+
+	if isHealth && !rf.isReadOnly.Load() {
+		list = rf.setManyToRedis(list, errs)
+	}
+	rf.setManyToMemory(list, errs)
+
+	return errs
+}
+
+// * 回傳未寫入 Redis、需改存本地的項目
+func (rf *RedisFallback) setManyToRedis(list []Cache, errs map[string]error) []Cache {
+	ctx := context.Background()
+
+	pending := make([]setManyItem, 0, len(list))
+	for _, item := range list {
+		data, err := rf.marshalCache(item)
+		if err != nil {
+			errs[item.Key] = newOpError(rf.logger, "set", item.Key, TierRedis, parseError(err))
+			continue
+		}
+		rf.markRecoveryWrite(item.Key)
+		pending = append(pending, setManyItem{item: item, data: data})
+	}
+
+	var err error
+	for i := 0; len(pending) > 0 && rf.canRetry(i); i++ {
+		pipe := rf.redis.Pipeline()
+		cmds := make([]*redis.StatusCmd, len(pending))
+		for j, p := range pending {
+			cmds[j] = pipe.SetArgs(ctx, p.item.Key, p.data, setArgs(p.item))
+		}
+		pipe.Exec(ctx)
+
+		// * Retry only the items that failed
+		failed := pending[:0]
+		for j, cmd := range cmds {
+			if isReplyError(cmd.Err()) {
+				errs[pending[j].item.Key] = newOpError(rf.logger, "set", pending[j].item.Key, TierRedis, cmd.Err())
+				continue
+			}
+			if cmd.Err() != nil {
+				err = cmd.Err()
+				failed = append(failed, pending[j])
+				continue
+			}
+			if rf.config.Options.DisableMirror {
+				rf.deleteCache(pending[j].item.Key)
+			} else {
+				rf.storeCache(pending[j].item.Key, pending[j].item)
+			}
+		}
+		pending = failed
+		if rf.stopRetry(err) {
+			break
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	rf.redisFailed("setmany", "", err)
+
+	rest := make([]Cache, len(pending))
+	for i, p := range pending {
+		rest[i] = p.item
+	}
+	return rest
+}
+
+// * 放入記憶體與待寫入佇列，無法排入佇列的項目合併為一次批次寫入
+func (rf *RedisFallback) setManyToMemory(list []Cache, errs map[string]error) {
+	var overflow []WriteRequest
+	for _, item := range list {
+		if req, ok := rf.setManyItem(item, errs); ok {
+			overflow = append(overflow, req)
+		}
+	}
+
+	// * Memory only while the disk is down, values are kept in memory
+	if len(overflow) > 0 && !rf.writer.diskDown.Load() {
+		rf.writer.flush(overflow)
+	}
+}
+
+// * 與 SetNX / SetXX 使用同一金鑰鎖；回傳無法排入佇列、需由呼叫端直接寫入的請求
+func (rf *RedisFallback) setManyItem(item Cache, errs map[string]error) (WriteRequest, bool) {
+	if err := rf.checkQuota(item.Key, item); err != nil {
+		errs[item.Key] = err
+		return WriteRequest{}, false
+	}
+
+	unlock := rf.conditional.lock(item.Key)
+	defer unlock()
+
+	rf.offlineWrites.Add(1)
+	rf.markDirty(item.Key)
+	rf.tombstones.Delete(item.Key)
+	req := WriteRequest{Key: item.Key, Data: item}
+
+	// * Not admitted to memory, must reach the file in this batch
+	if !rf.storeCache(item.Key, item) {
+		rf.writer.remove(item.Key)
+
+		if rf.writer.diskDown.Load() {
+			errs[item.Key] = &OpError{Op: "set", Key: item.Key, Tier: TierFile, Err: ErrDiskUnavailable}
+			return WriteRequest{}, false
+		}
+		return req, true
+	}
+
+	if !rf.writer.push(req) {
+		return req, true
+	}
+	return WriteRequest{}, false
+}
@@ -0,0 +1,91 @@
+package redisFallback
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const shardReplicas = 100 // 每個節點在一致性雜湊環上的虛擬節點數
+
+// * 以一致性雜湊分散至多台 Redis，各分片獨立降級
+type Sharded struct {
+	shards map[string]*RedisFallback
+	ring   []uint32
+	nodes  map[uint32]string
+}
+
+// * servers 為名稱對應 Redis 設定，名稱同時作為本地目錄 {DBPath}/{name}
+func NewSharded(c Config, servers map[string]*Redis) (*Sharded, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("At least one server is required")
+	}
+
+	c, err := resolveOptions(c)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sharded{
+		shards: make(map[string]*RedisFallback, len(servers)),
+		nodes:  make(map[uint32]string),
+	}
+
+	for name, server := range servers {
+		config := c
+		config.Redis = server
+		// * Separate fallback folder per shard, recovery only syncs its own files
+		config = withName(config, name)
+
+		rf, err := New(config)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.shards[name] = rf
+
+		for i := 0; i < shardReplicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(name + "#" + strconv.Itoa(i)))
+			s.nodes[hash] = name
+			s.ring = append(s.ring, hash)
+		}
+	}
+
+	sort.Slice(s.ring, func(i, j int) bool {
+		return s.ring[i] < s.ring[j]
+	})
+
+	return s, nil
+}
+
+// * 取得金鑰所屬的分片
+func (s *Sharded) Shard(key string) *RedisFallback {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(s.ring), func(i int) bool {
+		return s.ring[i] >= hash
+	})
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.shards[s.nodes[s.ring[i]]]
+}
+
+func (s *Sharded) Get(key string) (interface{}, error) {
+	return s.Shard(key).Get(key)
+}
+
+func (s *Sharded) Set(key string, value interface{}, ttl time.Duration) error {
+	return s.Shard(key).Set(key, value, ttl)
+}
+
+func (s *Sharded) Del(key string) error {
+	return s.Shard(key).Del(key)
+}
+
+func (s *Sharded) Close() {
+	for _, rf := range s.shards {
+		rf.Close()
+	}
+}
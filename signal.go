@@ -0,0 +1,43 @@
+package redisFallback
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// * 立即將待寫入佇列寫入本地檔案，回傳寫入筆數
+func (rf *RedisFallback) FlushPending() int {
+	return rf.writer.flushAll()
+}
+
+// * 收到 SIGTERM / SIGINT 時寫入待寫入資料並關閉實例，再以原訊號結束程序
+// * 回傳的函式可取消監聽
+func (rf *RedisFallback) HandleSignals() func() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM, os.Interrupt)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case sig := <-ch:
+			signal.Stop(ch)
+			count := rf.FlushPending()
+			rf.logger.Info("Flushed pending writes before exit", count)
+			rf.Close()
+
+			// * Re-raise so the process exits with the default behavior
+			if process, err := os.FindProcess(os.Getpid()); err == nil && process.Signal(sig) == nil {
+				return
+			}
+			os.Exit(1)
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
@@ -0,0 +1,197 @@
+package redisFallback
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+)
+
+const zsetMaxLevel = 32 // 與 Redis 相同，每層機率 1/4
+
+// * 本地有序集合：依分數排序的跳躍串列，同分時依成員字母排序，與 Redis 相同；span 記錄跨過的節點數，依名次取範圍為 O(log n)
+// * 寫入時複製一份修改，讀取端與待寫入佇列持有的版本不會變動
+type zsetList struct {
+	head   *zsetNode
+	level  int
+	length int
+	scores map[string]float64
+}
+
+type zsetNode struct {
+	member string
+	score  float64
+	levels []zsetLevel
+}
+
+type zsetLevel struct {
+	next *zsetNode
+	span int
+}
+
+func newZSetList() *zsetList {
+	return &zsetList{
+		head:   &zsetNode{levels: make([]zsetLevel, zsetMaxLevel)},
+		level:  1,
+		scores: make(map[string]float64),
+	}
+}
+
+func zsetBefore(node *zsetNode, score float64, member string) bool {
+	return node.score < score || node.score == score && node.member < member
+}
+
+func zsetRandomLevel() int {
+	level := 1
+	for level < zsetMaxLevel && rand.IntN(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// * 設定成員的分數，已存在時移除後重新插入
+func (l *zsetList) set(member string, score float64) {
+	if old, ok := l.scores[member]; ok {
+		if old == score {
+			return
+		}
+		l.remove(member, old)
+	}
+	l.insert(member, score)
+	l.scores[member] = score
+}
+
+func (l *zsetList) insert(member string, score float64) {
+	var update [zsetMaxLevel]*zsetNode
+	var rank [zsetMaxLevel]int
+
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].next != nil && zsetBefore(x.levels[i].next, score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].next
+		}
+		update[i] = x
+	}
+
+	level := zsetRandomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			update[i] = l.head
+			update[i].levels[i].span = l.length
+		}
+		l.level = level
+	}
+
+	node := &zsetNode{member: member, score: score, levels: make([]zsetLevel, level)}
+	for i := 0; i < level; i++ {
+		node.levels[i].next = update[i].levels[i].next
+		update[i].levels[i].next = node
+		node.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	// * Higher levels now skip one more node
+	for i := level; i < l.level; i++ {
+		update[i].levels[i].span++
+	}
+	l.length++
+}
+
+func (l *zsetList) remove(member string, score float64) {
+	var update [zsetMaxLevel]*zsetNode
+
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].next != nil && zsetBefore(x.levels[i].next, score, member) {
+			x = x.levels[i].next
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].next
+	if x == nil || x.score != score || x.member != member {
+		return
+	}
+	for i := 0; i < l.level; i++ {
+		if update[i].levels[i].next == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].next = x.levels[i].next
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	for l.level > 1 && l.head.levels[l.level-1].next == nil {
+		l.level--
+	}
+	l.length--
+	delete(l.scores, member)
+}
+
+// * 第 rank 個節點（從 1 起算）
+func (l *zsetList) byRank(rank int) *zsetNode {
+	x := l.head
+	traversed := 0
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].next != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].next
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// * 第 from 到 to 個成員（從 0 起算，含 to）
+func (l *zsetList) rangeByRank(from int, to int) []string {
+	members := make([]string, 0, to-from+1)
+	for x := l.byRank(from + 1); x != nil && len(members) < to-from+1; x = x.levels[0].next {
+		members = append(members, x.member)
+	}
+	return members
+}
+
+// * 依序走訪，fn 回傳 false 時停止
+func (l *zsetList) each(fn func(member string, score float64) bool) {
+	for x := l.head.levels[0].next; x != nil; x = x.levels[0].next {
+		if !fn(x.member, x.score) {
+			return
+		}
+	}
+}
+
+// * 已排序，逐一接在每層的結尾，O(n)
+func (l *zsetList) clone() *zsetList {
+	c := newZSetList()
+	var tails [zsetMaxLevel]*zsetNode
+	var ranks [zsetMaxLevel]int
+	for i := range tails {
+		tails[i] = c.head
+	}
+
+	l.each(func(member string, score float64) bool {
+		c.length++
+		node := &zsetNode{member: member, score: score, levels: make([]zsetLevel, zsetRandomLevel())}
+		for i := range node.levels {
+			tails[i].levels[i].next = node
+			tails[i].levels[i].span = c.length - ranks[i]
+			tails[i] = node
+			ranks[i] = c.length
+		}
+		c.level = max(c.level, len(node.levels))
+		c.scores[member] = score
+		return true
+	})
+	// * The last node of each level spans to the end, as insert expects
+	for i := 0; i < c.level; i++ {
+		tails[i].levels[i].span = c.length - ranks[i]
+	}
+	return c
+}
+
+// * 寫入本地檔案時與之前的格式相同，為成員對應分數的物件
+func (l *zsetList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.scores)
+}
@@ -0,0 +1,63 @@
+package redisFallback
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// * 隨機設定與移除成員，與排序後的切片比對名次範圍與複本
+func TestZSetListMatchesSortedOrder(t *testing.T) {
+	list := newZSetList()
+	scores := make(map[string]float64)
+
+	for i := 0; i < 2000; i++ {
+		member := "m" + strconv.Itoa(rand.IntN(200))
+		if old, ok := scores[member]; ok && rand.IntN(3) == 0 {
+			list.remove(member, old)
+			delete(scores, member)
+			continue
+		}
+		// * Few distinct scores, ties fall back to member order
+		score := float64(rand.IntN(20))
+		list.set(member, score)
+		scores[member] = score
+	}
+
+	want := make([]string, 0, len(scores))
+	for member := range scores {
+		want = append(want, member)
+	}
+	sort.Slice(want, func(i, j int) bool {
+		a, b := scores[want[i]], scores[want[j]]
+		if a != b {
+			return a < b
+		}
+		return want[i] < want[j]
+	})
+
+	for _, l := range []*zsetList{list, list.clone()} {
+		if l.length != len(want) {
+			t.Fatalf("length = %d, want %d", l.length, len(want))
+		}
+		for from := 0; from < len(want); from += 7 {
+			for to := from; to < len(want); to += 13 {
+				if got := l.rangeByRank(from, to); !slices.Equal(got, want[from:to+1]) {
+					t.Fatalf("rangeByRank(%d, %d) = %v, want %v", from, to, got, want[from:to+1])
+				}
+			}
+		}
+	}
+
+	// * Writes to the clone leave the original untouched
+	c := list.clone()
+	c.set("new", -1)
+	if _, ok := list.scores["new"]; ok || list.length != len(want) {
+		t.Fatal("clone shares state with the original")
+	}
+	if got := c.rangeByRank(0, 0); !slices.Equal(got, []string{"new"}) {
+		t.Fatalf("clone first member = %v, want [new]", got)
+	}
+}
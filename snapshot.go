@@ -0,0 +1,45 @@
+package redisFallback
+
+import (
+	"sort"
+)
+
+type MemoryEntry struct {
+	Key       string `json:"key"`
+	Type      string `json:"type"`
+	Size      int64  `json:"size"`                // 估算的大小（位元組）
+	Timestamp int64  `json:"timestamp"`           // 寫入時間
+	TTL       int64  `json:"ttl"`                 // 存活時間（秒），0 代表不過期
+	ExpireAt  int64  `json:"expire_at,omitempty"` // 到期時間
+}
+
+// * 記憶體層的唯讀快照，只包含中繼資料不含值，依金鑰排序
+// * 與清理迴圈互斥，快照中不會出現清理到一半的狀態
+func (rf *RedisFallback) SnapshotMemory() []MemoryEntry {
+	rf.cleanupMutex.RLock()
+	defer rf.cleanupMutex.RUnlock()
+
+	var list []MemoryEntry
+	rf.cache.Range(func(key, value interface{}) bool {
+		item := value.(Cache)
+		entry := MemoryEntry{
+			Key:       key.(string),
+			Type:      item.Type,
+			Timestamp: item.Timestamp,
+			TTL:       item.TTL,
+		}
+		if size, ok := rf.sizes.Load(key); ok {
+			entry.Size = size.(int64)
+		}
+		if item.TTL > 0 {
+			entry.ExpireAt = item.Timestamp + item.TTL
+		}
+		list = append(list, entry)
+		return true
+	})
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
@@ -0,0 +1,53 @@
+package redisFallback
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+const (
+	statsFilename = "stats.jsonl"   // 降級統計檔名，位於 DBPath 下
+	statsMaxSize  = 1 * 1024 * 1024 // 統計檔超過此大小時輪替
+)
+
+// * 模式切換與同步結果，供事後檢討使用
+type statsEvent struct {
+	Time          int64  `json:"time"`
+	Event         string `json:"event"`
+	OfflineWrites int64  `json:"offline_writes,omitempty"`
+	Synced        int    `json:"synced,omitempty"`
+	Failed        int    `json:"failed,omitempty"`
+	Duration      int64  `json:"duration_ms,omitempty"`
+}
+
+func (rf *RedisFallback) recordStats(event statsEvent) {
+	event.Time = rf.now().Unix()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	rf.statsMutex.Lock()
+	defer rf.statsMutex.Unlock()
+
+	if err := os.MkdirAll(rf.config.Options.DBPath, rf.config.Options.DirMode); err != nil {
+		rf.logger.Error(err, "Failed to create folder")
+		return
+	}
+
+	path := filepath.Join(rf.config.Options.DBPath, statsFilename)
+	// * Keep one rotated file
+	if info, err := os.Stat(path); err == nil && info.Size() > statsMaxSize {
+		os.Rename(path, path+".1")
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, rf.config.Options.FileMode)
+	if err != nil {
+		rf.logger.Error(err, "Failed to write stats")
+		return
+	}
+	defer file.Close()
+
+	file.Write(append(data, '\n'))
+}
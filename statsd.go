@@ -0,0 +1,93 @@
+//go:build !nometrics
+
+package redisFallback
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const defaultStatsDInterval = 10 * time.Second // 預設 statsd 推送間隔
+
+// * 定期以 statsd 協定推送計數與狀態
+func (rf *RedisFallback) startStatsD() {
+	s := rf.config.Options.StatsD
+	if s == nil || s.Address == "" {
+		return
+	}
+
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "redis_fallback"
+	}
+	if rf.config.Name != "" {
+		prefix += "." + rf.config.Name
+	}
+	interval := s.Interval
+	if interval <= 0 {
+		interval = defaultStatsDInterval
+	}
+
+	conn, err := net.Dial("udp", s.Address)
+	if err != nil {
+		rf.logger.Error(err, "Failed to connect statsd")
+		return
+	}
+
+	ticker := rf.config.Options.Clock.NewTicker(interval)
+	rf.goroutine(func() {
+		defer conn.Close()
+
+		last := make(map[string]int64)
+		for {
+			select {
+			case <-rf.closed:
+				ticker.Stop()
+				return
+			case <-ticker.C():
+				var lines []string
+				counters := rf.counters()
+				gauges := make(map[string]int64)
+				for name, stats := range rf.namespaces.stats() {
+					counters["namespace."+name+".hits"] = stats.Hits
+					counters["namespace."+name+".misses"] = stats.Misses
+					counters["namespace."+name+".sets"] = stats.Sets
+					counters["namespace."+name+".rejected"] = stats.Rejected
+					counters["namespace."+name+".evicted"] = stats.Evicted
+					gauges["namespace."+name+".memory_bytes"] = stats.MemoryBytes
+					gauges["namespace."+name+".memory_keys"] = stats.MemoryKeys
+					gauges["namespace."+name+".offline_writes"] = stats.OfflineWrites
+				}
+
+				for name, value := range counters {
+					if delta := value - last[name]; delta > 0 {
+						lines = append(lines, fmt.Sprintf("%s.%s:%d|c", prefix, name, delta))
+					}
+					last[name] = value
+				}
+				for name, value := range gauges {
+					lines = append(lines, fmt.Sprintf("%s.%s:%d|g", prefix, name, value))
+				}
+
+				rf.mutex.RLock()
+				health := 0
+				if rf.isHealth {
+					health = 1
+				}
+				rf.mutex.RUnlock()
+
+				lines = append(lines,
+					fmt.Sprintf("%s.health:%d|g", prefix, health),
+					fmt.Sprintf("%s.queue:%d|g", prefix, rf.backlog()),
+					fmt.Sprintf("%s.memory_bytes:%d|g", prefix, rf.MemoryUsage()),
+				)
+
+				if _, err := conn.Write([]byte(strings.Join(lines, "\n"))); err != nil {
+					rf.logger.Error(err, "Failed to send statsd")
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,10 @@
+//go:build nometrics
+
+package redisFallback
+
+// * 以 nometrics 編譯時不推送 statsd，Options.StatsD 會被忽略
+func (rf *RedisFallback) startStatsD() {
+	if s := rf.config.Options.StatsD; s != nil && s.Address != "" {
+		rf.logger.Info("Built with nometrics, StatsD is ignored")
+	}
+}
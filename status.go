@@ -0,0 +1,69 @@
+package redisFallback
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const maxLastErrors = 10 // 狀態文件保留的最近錯誤數
+
+type Status struct {
+	Name          string                    `json:"name,omitempty"`
+	Mode          string                    `json:"mode"`
+	ModeSince     int64                     `json:"mode_since"`
+	UptimeInMode  float64                   `json:"uptime_in_mode"`
+	IsRecovering  bool                      `json:"is_recovering"`
+	IsReadOnly    bool                      `json:"is_read_only"`
+	IsDiskDown    bool                      `json:"is_disk_down"`
+	IsFailClosed  bool                      `json:"is_fail_closed"`
+	QueueDepth    int                       `json:"queue_depth"`
+	MemoryEntries int                       `json:"memory_entries"`
+	MemoryBytes   int64                     `json:"memory_bytes"`
+	Counters      map[string]int64          `json:"counters"`
+	LastErrors    []StatusError             `json:"last_errors"`
+	Transitions   []Transition              `json:"transitions"`
+	LastPing      int64                     `json:"last_ping"`
+	Namespaces    map[string]NamespaceStats `json:"namespaces,omitempty"`
+}
+
+type StatusError struct {
+	Time    int64  `json:"time"`
+	Message string `json:"message"`
+}
+
+func (rf *RedisFallback) Status() Status {
+	rf.mutex.RLock()
+	mode := modeName(rf.isHealth)
+	rf.mutex.RUnlock()
+
+	since := rf.modeSince.Load()
+	entries := 0
+	rf.cache.Range(func(key, value interface{}) bool {
+		entries++
+		return true
+	})
+
+	return Status{
+		Name:          rf.config.Name,
+		Mode:          mode,
+		ModeSince:     since,
+		UptimeInMode:  rf.now().Sub(time.Unix(since, 0)).Seconds(),
+		IsRecovering:  rf.isRecovering.Load(),
+		IsReadOnly:    rf.isReadOnly.Load(),
+		IsDiskDown:    rf.writer.diskDown.Load(),
+		IsFailClosed:  rf.failClosed.Load(),
+		QueueDepth:    rf.backlog(),
+		MemoryEntries: entries,
+		MemoryBytes:   rf.MemoryUsage(),
+		Counters:      rf.counters(),
+		LastErrors:    rf.logger.lastErrors(),
+		Transitions:   rf.transitionHistory(),
+		LastPing:      rf.lastPing.Load(),
+		Namespaces:    rf.namespaces.stats(),
+	}
+}
+
+// * 供監控腳本使用的 JSON 狀態文件
+func (rf *RedisFallback) StatusJSON() ([]byte, error) {
+	return json.Marshal(rf.Status())
+}
@@ -2,134 +2,350 @@ package redisFallback
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func (rf *RedisFallback) syncToRedis(key string, cache Cache) {
+	// * Counters and lists only reconcile their offline changes during recovery
+	if cache.Type == counterType || cache.Type == listType {
+		return
+	}
 	ctx := context.Background()
-	data, err := json.Marshal(cache.Data)
-	data = []byte(strings.Trim(string(data), "\""))
-	if err != nil {
+	if _, err := rf.writeItem(ctx, rf.redis, key, cache); err != nil {
 		rf.logger.Error(err, "Failed to parse")
-		return
 	}
-	rf.redis.Set(ctx, key, data, time.Duration(cache.TTL)*time.Second)
 }
 
-func (rf *RedisFallback) changeToFallbackMode() {
-	// rf.sendEmail("Redis connection failed, starting health check")
+func (rf *RedisFallback) changeToFallbackMode(cause string) {
+	if rf.isHealth || rf.modeSince.Load() == 0 {
+		rf.metrics.fallbacks.Add(1)
+		rf.modeSince.Store(rf.now().Unix())
+		rf.recordStats(statsEvent{Event: EventFallback})
+		rf.recordTransition(modeName(false), cause)
+		rf.notifyFallback(rf.metricsSnapshot())
+		rf.scheduleFallbackLimit()
+	}
 	rf.isHealth = false
 
-	if rf.checker != nil {
+	if rf.checker != nil || rf.sharedChecker {
 		return
 	}
 
-	rf.checker = time.NewTicker(rf.config.Option.TimeToCheck)
-	go func() {
-		for range rf.checker.C {
+	// * rf.checker is guarded by rf.mutex, the loop keeps its own reference
+	checker := rf.config.Options.Clock.NewTicker(rf.config.Options.TimeToCheck)
+	rf.checker = checker
+	rf.goroutine(func() {
+		defer checker.Stop()
+		for {
+			select {
+			case <-rf.closed:
+				return
+			case <-checker.C():
+			}
 			ctx := context.Background()
-			if err := rf.redis.Ping(ctx).Err(); err == nil {
+			if err := rf.checkHealthy(ctx); err == nil {
 				rf.mutex.Lock()
-				go rf.changeToNormalMode()
-				rf.mutex.Unlock()
-
-				rf.checker.Stop()
+				rf.changeToNormalMode("health check")
 				rf.checker = nil
+				rf.mutex.Unlock()
 				return
 			}
 		}
-	}()
+	})
 }
 
-func (rf *RedisFallback) changeToNormalMode() error {
-	folderPath := filepath.Join(rf.config.Option.DBPath, strconv.Itoa(rf.config.Redis.DB))
+// * Redis 回覆的錯誤（WRONGTYPE、未知指令等）代表 Redis 仍可用，重試不會成功；READONLY 依 ReadOnlyDegrade 處理
+func isReplyError(err error) bool {
+	var reply redis.Error
+	return err != redis.Nil && errors.As(err, &reply) && !isReadOnlyError(err)
+}
 
-	var files []string
-	err := filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(path, ".json") {
-			files = append(files, path)
-		}
+// * 回覆錯誤與 Redis 拒絕寫入時不再重試
+func (rf *RedisFallback) stopRetry(err error) bool {
+	return isReplyError(err) || rf.rejectsWrites(err)
+}
+
+// * 指令重試失敗後呼叫；Redis 回覆的錯誤回傳給呼叫端，其餘切換模式後回傳 nil，呼叫端接著改用本地儲存
+// * ReadOnlyDegrade 下 Redis 拒絕寫入時只切換至唯讀模式
+func (rf *RedisFallback) redisFailed(op string, key string, err error) error {
+	// * Redis answered, the command itself is wrong for this key
+	if isReplyError(err) {
+		return newOpError(rf.logger, op, key, TierRedis, err)
+	}
+
+	// * Reads still work, only spool writes locally
+	if rf.rejectsWrites(err) {
+		rf.changeToReadOnlyMode()
 		return nil
+	}
+
+	messages := []any{"[" + strings.ToUpper(op) + "] Switching to fallback mode"}
+	if key != "" {
+		messages = append(messages, rf.logger.key(key))
+	}
+	rf.logger.Info(messages...)
+	rf.mutex.Lock()
+	rf.changeToFallbackMode(op + " retries exhausted")
+	rf.mutex.Unlock()
+	return nil
+}
+
+// * 立即切換為正常模式服務新請求，本地資料於背景同步至 Redis
+func (rf *RedisFallback) changeToNormalMode(cause string) {
+	rf.isHealth = true
+	rf.detector.reset()
+	rf.failClosed.Store(false)
+	rf.metrics.recoveries.Add(1)
+	rf.modeSince.Store(rf.now().Unix())
+	rf.recordStats(statsEvent{Event: EventNormal})
+	rf.recordTransition(modeName(true), cause)
+	rf.notifyNormal(rf.metricsSnapshot())
+
+	if !rf.isRecovering.CompareAndSwap(false, true) {
+		rf.logger.Info("Already running recovery")
+		return
+	}
+	rf.goroutine(rf.recover)
+}
+
+func (rf *RedisFallback) recover() {
+	defer rf.isRecovering.Store(false)
+	defer rf.recoverySkip.Clear()
+
+	// * Offline writes after this point get a new sequence and keep their files
+	dirty := make(map[string]int64)
+	rf.dirty.Range(func(key, value interface{}) bool {
+		dirty[key.(string)] = value.(int64)
+		return true
 	})
+	fallbacks := rf.metrics.fallbacks.Load()
 
-	if err != nil {
-		return rf.logger.Error(err, "Failed to search folder")
+	// * Deletes are not kept in memory, queued ones must reach the files first
+	rf.writer.flushAll()
+	rf.writer.checkpoint()
+
+	folders := []string{filepath.Join(rf.config.Options.DBPath, strconv.Itoa(rf.config.Redis.DB))}
+	if rf.config.Options.ReplicaPath != "" {
+		folders = append(folders, replicaFolder(rf.config))
 	}
 
-	for _, file := range files {
-		data, err := os.ReadFile(file)
+	var files []string
+	for _, folderPath := range folders {
+		err := filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
+			// * No fallback files yet
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(path, ".json") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		// * Primary volume lost, the replica and memory may still hold the offline writes
 		if err != nil {
-			rf.logger.Error(err, "Failed to read file")
-			continue
+			rf.logger.Error(err, "Failed to search folder")
 		}
+	}
 
-		var cache Cache
-		if err := json.Unmarshal(data, &cache); err != nil {
-			rf.logger.Error(err, "Failed to parse")
+	latest, paths, dropped := rf.compactForRecovery(files)
+	if dropped > 0 {
+		rf.logger.Info("Compacted before recovery, dropped files", dropped)
+	}
+
+	// * Keys whose local copy Redis already has, their files can be removed
+	done := make(map[string]bool)
+	items := make(map[string]Cache, len(latest)+len(dirty))
+	for key, cache := range latest {
+		if local, ok := rf.memoryItem(key); ok {
+			_, isDirty := dirty[key]
+			if local.Timestamp > cache.Timestamp || (isDirty && local.Timestamp == cache.Timestamp) {
+				// * Memory holds a later offline write, or a newer value read from Redis
+				if isDirty {
+					items[key] = local
+				} else {
+					done[key] = true
+				}
+				continue
+			}
+		}
+		if cache.Type != tombstoneType {
+			rf.storeCache(key, cache)
+		}
+		items[key] = cache
+	}
+	// * Written while the disk was down, only memory has the value or delete
+	for key := range dirty {
+		if _, ok := items[key]; ok || done[key] {
 			continue
 		}
+		if local, ok := rf.memoryItem(key); ok {
+			items[key] = local
+			continue
+		}
+		done[key] = true
+	}
 
-		rf.cache.Store(cache.Key, cache)
+	var deletes []string
+	var sets []syncItem
+	now := rf.now()
+	for key, item := range items {
+		switch {
+		case item.Type == tombstoneType:
+			deletes = append(deletes, key)
+		case !needsSync(item) || isExpired(item, now):
+			done[key] = true
+		default:
+			sets = append(sets, syncItem{key: key, item: item})
+		}
 	}
 
-	rf.syncMemoryToRedis()
-	if err := rf.cleanupLocalFile(); err != nil {
-		rf.logger.Error(err, "Failed to cleanup")
+	start := rf.now()
+	deleted, failed := rf.syncTombstones(deletes)
+	set, setFailed := rf.syncItems(sets)
+	published, publishFailed := rf.replayMessages()
+	failed += setFailed + publishFailed
+	for _, key := range append(deleted, set...) {
+		done[key] = true
 	}
+	// * Written by new traffic since recovery started, Redis already has the latest value
+	rf.recoverySkip.Range(func(key, value interface{}) bool {
+		done[key.(string)] = true
+		return true
+	})
 
-	rf.isHealth = true
+	// * Redis dropped again during recovery, keep every file for the next recovery
+	if !rf.isHealthy() || rf.metrics.fallbacks.Load() != fallbacks {
+		rf.logger.Info("Fallback during recovery, skipped cleanup")
+	} else {
+		rf.cleanupLocalFile(done, dirty, paths)
 
-	return nil
+		// * Memory is only used during fallback
+		if rf.config.Options.DisableMirror {
+			rf.cache.Range(func(key, value interface{}) bool {
+				if _, ok := rf.dirty.Load(key); !ok {
+					rf.deleteCache(key.(string))
+				}
+				return true
+			})
+		}
+	}
+
+	synced := len(deleted) + len(set) + published
+	rf.namespaces.resetOffline()
+	rf.recordStats(statsEvent{
+		Event:         EventRecovered,
+		OfflineWrites: rf.offlineWrites.Swap(0),
+		Synced:        synced,
+		Failed:        failed,
+		Duration:      rf.now().Sub(start).Milliseconds(),
+	})
+	rf.notify(EventRecovered, fmt.Sprintf("synced %d, failed %d", synced, failed))
 }
 
-func (rf *RedisFallback) syncMemoryToRedis() {
-	if !rf.isRecovering.CompareAndSwap(false, true) {
-		rf.logger.Info("Already running recovery")
-		return
+// * 以差值同步的型別（計數器、清單）同步成功後扣除已送出的部分，避免下次復原重複套用
+func (rf *RedisFallback) settle(key string, item Cache) {
+	switch item.Type {
+	case counterType:
+		rf.settleCounter(key, item.Delta)
+	case listType:
+		rf.settleList(key, item)
+	case zsetType:
+		rf.settleZSet(key, item.Increments)
 	}
+}
 
-	defer rf.isRecovering.Store(false)
+// * 背景同步期間被新請求寫入或刪除的金鑰，同步時略過以免舊值覆蓋
+// * 只用於整份取代的值；雜湊、集合與有序集合的本地變更與 Redis 合併，略過會遺失降級期間的欄位與刪除
+func (rf *RedisFallback) markRecoveryWrite(key string) {
+	if rf.isRecovering.Load() {
+		rf.recoverySkip.Store(key, struct{}{})
+	}
+}
 
+// * 降級期間寫入、尚未同步至 Redis 的金鑰
+func (rf *RedisFallback) isDirty(key string) bool {
+	_, ok := rf.dirty.Load(key)
+	return ok
+}
+
+// * 降級期間寫入的金鑰，序號用來判斷復原期間是否再次寫入
+func (rf *RedisFallback) markDirty(key string) {
+	rf.dirty.Store(key, rf.dirtySeq.Add(1))
+}
+
+// * 復原開始後沒有再次寫入時清除標記，回傳是否可移除本地檔案
+func (rf *RedisFallback) clearDirty(key string, seq int64) bool {
+	if seq == 0 {
+		_, ok := rf.dirty.Load(key)
+		return !ok
+	}
+	return rf.dirty.CompareAndDelete(key, seq)
+}
+
+// * 沒有離線變動的計數器與已清空的清單不需要同步
+func needsSync(item Cache) bool {
+	if item.Type == counterType && item.Delta == 0 {
+		return false
+	}
+	if list, ok := item.Data.([]interface{}); ok && item.Type == listType && len(list) == 0 {
+		return false
+	}
+	return true
+}
+
+// * 回傳同步成功的金鑰與失敗的筆數，每批完成時發出進度通知
+func (rf *RedisFallback) syncItems(items []syncItem) ([]string, int) {
 	ctx := context.Background()
-	pipe := rf.redis.Pipeline()
-	count := 0
-	now := time.Now().Unix()
-
-	rf.cache.Range(func(key, value interface{}) bool {
-		item := value.(Cache)
-		if !isExpired(item) {
-			data, err := json.Marshal(item.Data)
-			data = []byte(strings.Trim(string(data), "\""))
+	var synced []string
+	failed := 0
+	exec := func(batch []syncItem) {
+		rf.reconcileTTL(ctx, batch)
+
+		pipe := rf.redis.Pipeline()
+		// * One result per item, hashes also queue EXPIREAT
+		var cmds []redis.Cmder
+		var sent []syncItem
+		for _, s := range batch {
+			if _, ok := rf.recoverySkip.Load(s.key); ok {
+				continue
+			}
+			cmd, err := rf.writeItem(ctx, pipe, s.key, s.item)
 			if err != nil {
 				rf.logger.Error(err, "Failed to parse")
-			} else {
-				remainingTTL := time.Duration(item.Timestamp+item.TTL-now) * time.Second
-				if remainingTTL > 0 {
-					pipe.Set(ctx, key.(string), data, remainingTTL)
-				}
+				failed++
+				continue
 			}
+			cmds = append(cmds, cmd)
+			sent = append(sent, s)
+		}
 
-			count++
-			if count%100 == 0 {
-				pipe.Exec(ctx)
-				pipe = rf.redis.Pipeline()
+		pipe.Exec(ctx)
+		for i, cmd := range cmds {
+			if cmd.Err() != nil {
+				failed++
+				continue
 			}
+			synced = append(synced, sent[i].key)
+			rf.settle(sent[i].key, sent[i].item)
 		}
-		return true
-	})
+		rf.notify(EventRecoveryProgress, fmt.Sprintf("synced %d, failed %d", len(synced), failed))
+	}
 
-	if count%100 != 0 {
-		pipe.Exec(ctx)
+	for start := 0; start < len(items); start += 100 {
+		exec(items[start:min(start+100, len(items))])
 	}
+	return synced, failed
 }
 
 func (rf *RedisFallback) startMemoryCleanup() {
@@ -138,80 +354,45 @@ func (rf *RedisFallback) startMemoryCleanup() {
 		return
 	}
 
-	ticker := time.NewTicker(30 * time.Second)
-	go func() {
-		for range ticker.C {
+	ticker := rf.config.Options.Clock.NewTicker(30 * time.Second)
+	rf.goroutine(func() {
+		for range ticker.C() {
+			rf.access.reset()
+			var expired []Cache
+			rf.cleanupMutex.Lock()
 			rf.cache.Range(func(key, value interface{}) bool {
 				item := value.(Cache)
-				if isExpired(item) {
-					rf.cache.Delete(key)
+				if isExpired(item, rf.now()) {
+					rf.deleteCache(key.(string))
 					rf.removeJSONFile(key.(string))
+					item.Key = key.(string)
+					expired = append(expired, item)
 				}
 				return true
 			})
+			rf.cleanupMutex.Unlock()
+			rf.runExpireHooks(expired)
 		}
-	}()
-}
-
-func (rf *RedisFallback) cleanupLocalFile() error {
-	var folderPath = rf.config.Option.DBPath + "/" + fmt.Sprintf("%d", rf.config.Redis.DB)
-
-	filesRemoved := 0
-	err := filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			rf.logger.Error(err, "Failed to search folder")
-			return nil
-		}
-
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
-			if err := os.Remove(path); err != nil {
-				rf.logger.Error(err, "Failed to remove file")
-			} else {
-				filesRemoved++
-			}
-		}
-		return nil
 	})
-
-	if err != nil {
-		rf.logger.Error(err, "Failed to remove file")
-		return err
-	}
-
-	rf.removeEmptyFolder(folderPath)
-
-	return nil
 }
 
-func (rf *RedisFallback) removeEmptyFolder(root string) int {
-	dirsRemoved := 0
-
-	var walkFn func(path string) error
-	walkFn = func(path string) error {
-		entries, err := os.ReadDir(path)
-		if err != nil {
-			rf.logger.Error(err, "Failed to read path")
-			return nil
-		}
-
-		for _, entry := range entries {
-			if entry.IsDir() {
-				subpath := filepath.Join(path, entry.Name())
-				walkFn(subpath)
-			}
+// * 只移除 Redis 已有其值、且復原期間沒有再次降級寫入的金鑰的檔案，其餘留待下次復原
+func (rf *RedisFallback) cleanupLocalFile(done map[string]bool, dirty map[string]int64, paths map[string][]string) {
+	for key := range done {
+		if !rf.clearDirty(key, dirty[key]) {
+			continue
 		}
-
-		if len(entries) == 0 && path != root {
-			err := os.Remove(path)
-			if err != nil {
-				rf.logger.Error(err, "Failed to remove path")
-			} else {
-				dirsRemoved++
+		rf.tombstones.Delete(key)
+		// * Queued write only restates what Redis has
+		rf.writer.remove(key)
+		rf.removeJSONFile(key)
+		// * Replica copies and files left by a previous path layout
+		primary := getPath(rf.config, key).filepath
+		for _, path := range paths[key] {
+			if path != primary {
+				os.Remove(path)
 			}
 		}
-		return nil
 	}
-
-	walkFn(root)
-	return dirsRemoved
+	rf.prune(defaultPruneBatch)
 }
@@ -0,0 +1,59 @@
+package redisFallback
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// * 立即將指定金鑰的本地值寫入 Redis，不等待復原流程；回傳失敗的金鑰與錯誤
+func (rf *RedisFallback) SyncKeys(keys ...string) map[string]error {
+	errs := make(map[string]error)
+	if !rf.isHealthy() || rf.isReadOnly.Load() {
+		for _, key := range keys {
+			errs[key] = &OpError{Op: "sync", Key: key, Tier: TierRedis, Err: ErrRedisUnavailable}
+		}
+		return errs
+	}
+
+	ctx := context.Background()
+	pipe := rf.redis.Pipeline()
+	cmds := make(map[string]redis.Cmder, len(keys))
+	items := make(map[string]Cache, len(keys))
+	for _, key := range keys {
+		if err := rf.validateKey("sync", key); err != nil {
+			errs[key] = err
+			continue
+		}
+
+		item, ok := rf.localItem(key)
+		if !ok {
+			errs[key] = newOpError(rf.logger, "sync", key, TierFile, ErrNotFound)
+			continue
+		}
+
+		cmd, err := rf.writeItem(ctx, pipe, key, item)
+		if err != nil {
+			errs[key] = newOpError(rf.logger, "sync", key, TierRedis, parseError(err))
+			continue
+		}
+		cmds[key] = cmd
+		items[key] = item
+	}
+
+	if len(cmds) == 0 {
+		return errs
+	}
+
+	pipe.Exec(ctx)
+	for key, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			errs[key] = newOpError(rf.logger, "sync", key, TierRedis, err)
+			continue
+		}
+		// * Redis has the local value, recovery must not replay it again
+		rf.markRecoveryWrite(key)
+		rf.settle(key, items[key])
+	}
+	return errs
+}
@@ -0,0 +1,57 @@
+package redisFallback
+
+import (
+	"context"
+)
+
+const tombstoneType = "tombstone" // 降級時 Del 留下的刪除紀錄，復原時對 Redis 執行 DEL
+
+// * 記憶體與檔案各留一份，之後的寫入會取代同一金鑰的紀錄；磁碟無法寫入時只留在記憶體並回傳 ErrDiskUnavailable
+func (rf *RedisFallback) writeTombstone(key string) error {
+	rf.offlineWrites.Add(1)
+	rf.markDirty(key)
+	item := Cache{Key: key, Type: tombstoneType, Timestamp: rf.now().Unix()}
+	rf.tombstones.Store(key, item)
+
+	// * Built with nofallbackdisk, deletes are kept in memory like every other write
+	if !fallbackDisk {
+		return nil
+	}
+	if rf.writer.diskDown.Load() {
+		return &OpError{Op: "del", Key: key, Tier: TierFile, Err: ErrDiskUnavailable}
+	}
+	if !rf.writer.push(WriteRequest{Key: key, Data: item, Priority: PriorityHigh}) {
+		return rf.writer.writeToFile(key, item)
+	}
+	return nil
+}
+
+// * 記憶體層的值或降級期間的刪除紀錄，兩者都有時取較新的
+func (rf *RedisFallback) memoryItem(key string) (Cache, bool) {
+	var item Cache
+	cached, ok := rf.cache.Load(key)
+	if ok {
+		item = cached.(Cache)
+	}
+	if deleted, found := rf.tombstones.Load(key); found && (!ok || deleted.(Cache).Timestamp >= item.Timestamp) {
+		return deleted.(Cache), true
+	}
+	return item, ok
+}
+
+// * 每批 100 個金鑰，回傳同步成功的金鑰與失敗的筆數
+func (rf *RedisFallback) syncTombstones(keys []string) ([]string, int) {
+	ctx := context.Background()
+	var synced []string
+	failed := 0
+	for start := 0; start < len(keys); start += 100 {
+		end := min(start+100, len(keys))
+		if err := rf.redis.Del(ctx, keys[start:end]...).Err(); err != nil {
+			rf.logger.Error(err, "Failed to sync deletes")
+			failed += end - start
+			continue
+		}
+		synced = append(synced, keys[start:end]...)
+	}
+	return synced, failed
+}
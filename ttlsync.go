@@ -0,0 +1,87 @@
+package redisFallback
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultTTLSyncSample = 200 // 每次同步最多檢查的記憶體金鑰數
+
+// * 定期以 PTTL 抽查記憶體層的金鑰，移除已在 Redis 被刪除或提前過期的項目
+func (rf *RedisFallback) startTTLSync() {
+	interval := rf.config.Options.TimeToSyncTTL
+	if interval <= 0 {
+		return
+	}
+
+	ticker := rf.config.Options.Clock.NewTicker(interval)
+	rf.goroutine(func() {
+		for {
+			select {
+			case <-rf.closed:
+				ticker.Stop()
+				return
+			case <-ticker.C():
+				rf.syncTTL(defaultTTLSyncSample)
+			}
+		}
+	})
+}
+
+// * 只在正常模式下執行，降級時記憶體層才是最新的資料
+func (rf *RedisFallback) syncTTL(limit int) int {
+	rf.mutex.RLock()
+	isHealth := rf.isHealth
+	rf.mutex.RUnlock()
+	if !isHealth || rf.isRecovering.Load() {
+		return 0
+	}
+
+	// * sync.Map iteration order varies between calls, so each tick samples different keys
+	batch := make([]syncItem, 0, limit)
+	rf.cache.Range(func(key, value interface{}) bool {
+		batch = append(batch, syncItem{key: key.(string), item: value.(Cache)})
+		return len(batch) < limit
+	})
+	if len(batch) == 0 {
+		return 0
+	}
+
+	ctx := context.Background()
+	pipe := rf.redis.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(batch))
+	for i, s := range batch {
+		cmds[i] = pipe.PTTL(ctx, s.key)
+	}
+	pipe.Exec(ctx)
+
+	now := rf.now()
+	updated := 0
+	for i, cmd := range cmds {
+		pttl, err := cmd.Result()
+		if err != nil {
+			continue
+		}
+
+		// * Skip keys rewritten since the sample was taken
+		current, ok := rf.cache.Load(batch[i].key)
+		if !ok || current.(Cache).Timestamp != batch[i].item.Timestamp {
+			continue
+		}
+
+		// * -2: deleted or expired in Redis by another client
+		if pttl == -2 {
+			rf.deleteCache(batch[i].key)
+			updated++
+			continue
+		}
+
+		item := applyRemainingTTL(batch[i].item, pttl, now)
+		if item.TTL != batch[i].item.TTL {
+			rf.storeCache(batch[i].key, item)
+			updated++
+		}
+	}
+	return updated
+}
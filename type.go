@@ -2,6 +2,7 @@ package redisFallback
 
 import (
 	"context"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,8 +18,11 @@ const (
 	defaultDBPath       = "./files/redisFallback/db"
 	defaultMaxRetry     = 3
 	defaultMaxQueue     = 1000            // 最大排隊長度，預設 1000
+	defaultMaxWorker    = 8               // 寫入檔案的最大 worker 數，預設 8
 	defaultTimeToWrite  = 3 * time.Second // 預設 Fallback 模式下寫入時間間隔
 	defaultTimeToCheck  = 1 * time.Minute // 預設健康檢查時間間隔
+	defaultFileMode     = 0600            // 預設檔案權限，僅擁有者可讀寫
+	defaultDirMode      = 0700            // 預設目錄權限，僅擁有者可存取
 )
 
 // * 繼承至 pardnchiu/go-logger
@@ -26,60 +30,193 @@ type Log = goLogger.Log
 type Logger = goLogger.Logger
 
 type Config struct {
-	Redis  *Redis       `json:"redis"`            // Redis 設定
-	Log    *Log         `json:"log,omitempty"`    // 日誌設定
-	Option *Options     `json:"option,omitempty"` // 選項設定
-	Email  *EmailConfig `json:"email,omitempty"`  // Email 通知設定
+	Name    string       `json:"name,omitempty"`    // 實例名稱，用於日誌前綴、statsd 指標、通知與本地目錄 {DBPath}/{Name}
+	Redis   *Redis       `json:"redis"`             // Redis 設定
+	Log     *Log         `json:"log,omitempty"`     // 日誌設定
+	Options *Options     `json:"options,omitempty"` // 選項設定
+	Option  *Options     `json:"option,omitempty"`  // Deprecated: 請改用 Options，New 時對應至 Options
+	Email   *EmailConfig `json:"email,omitempty"`   // Email 通知設定
+	Ring    *Ring        `json:"ring,omitempty"`    // Redis Ring 設定，設定後取代 Redis
 }
 
 type Redis struct {
 	Host     string `json:"host"`               // Redis 主機位址
 	Port     int    `json:"port"`               // Redis 連接埠
-	Password string `json:"password,omitempty"` // Redis 密碼
+	Username string `json:"username,omitempty"` // Redis ACL 使用者，預設 default
+	Password string `json:"password,omitempty"` // Redis 密碼，可用 UpdateCredentials 輪替
 	DB       int    `json:"db"`                 // Redis 資料庫編號
 }
 
 type Options struct {
-	DBPath      string        // 預設資料庫路徑
-	MaxRetry    int           // 最大重試次數，預設 3
-	MaxQueue    int           // 最大排隊長度，預設 1000
-	TimeToWrite time.Duration // Fallback 模式下寫入時間間隔，預設 3 秒
-	TimeToCheck time.Duration // 健康檢查時間間隔，預設 1 分鐘
+	DBPath              string            // 預設資料庫路徑
+	ReplicaPath         string            // 降級寫入的第二份副本目錄（例如 NFS 或另一顆磁碟），主要目錄遺失時復原仍可讀取，預設關閉
+	MaxRetry            int               // 最大重試次數，預設 3
+	RetryBudget         int               // 每秒所有操作共用的重試次數上限，用完時不再重試直接切換至降級模式，預設 0 不限制
+	MaxQueue            int               // 最大排隊長度，預設 1000
+	MaxWorker           int               // 每次批次寫入的最大 worker 數，預設 8
+	MaxWriteConcurrency int               // 同時寫入檔案的最大數量（批次 worker 與佇列滿時的直接寫入合計），預設與 MaxWorker 相同
+	TimeToWrite         time.Duration     // Fallback 模式下寫入時間間隔，預設 3 秒
+	FlushSize           int               // 待寫入筆數達到此數量時不等待 TimeToWrite 立即寫入，預設 0 不啟用
+	FlushBytes          int64             // 待寫入資料大小（位元組）達到此數量時立即寫入，預設 0 不啟用
+	SegmentWrites       bool              // 排程寫入依第一層分片附加至 segment.log，一個分片一次寫入，掃描與復原前展開為金鑰檔案，預設關閉
+	TimeToCheck         time.Duration     // 健康檢查時間間隔，預設 1 分鐘
+	Encoder             Encoder           // JSON 編碼器，預設 encoding/json
+	HedgedRead          bool              // 同時查詢 Redis 與本地，回傳最先取得的結果，預設關閉
+	Label               string            // 日誌前綴的實例標籤，同一程序有多個實例時使用
+	KeyRedaction        string            // 日誌中金鑰的遮蔽方式：hash / truncate，預設不遮蔽
+	AuditPath           string            // Set / Del 稽核檔案路徑，預設不記錄
+	AuditFunc           func(AuditEntry)  // Set / Del 稽核回呼，預設不記錄
+	StatsD              *StatsD           // statsd 推送設定，預設關閉
+	DisableMirror       bool              // 正常模式下不寫入記憶體層，只在降級時使用，預設關閉
+	Debounce            time.Duration     // 同一金鑰寫入檔案的最短間隔，預設 0 每次刷新都寫入
+	MaxItemSize         int64             // 超過此大小（位元組）的值不放入記憶體層，預設 0 不限制
+	TimeToCompact       time.Duration     // 本地檔案壓縮排程間隔，預設 0 不啟用
+	TimeToPrune         time.Duration     // 空目錄清理排程間隔，預設 10 分鐘
+	TimeToSyncTTL       time.Duration     // 正常模式下以 Redis 的 PTTL 校正記憶體層存活時間的排程間隔，預設 0 不啟用
+	Preload             int               // 以降級模式啟動時預先載入記憶體的最近檔案數，預設 0 不載入
+	FileMode            os.FileMode       // 本地檔案權限（仍受 umask 影響），預設 0600
+	DirMode             os.FileMode       // 本地目錄權限（仍受 umask 影響），預設 0700
+	KeyPolicy           *KeyPolicy        // 金鑰檢查規則，空金鑰與控制字元預設拒絕
+	ReadOnlyDegrade     bool              // Redis 拒絕寫入時讀取仍走 Redis，寫入改存本地，預設關閉
+	Hook                Hook              // 故障注入點，供測試與混沌工具使用，預設無
+	Clock               Clock             // 時間來源，測試時可替換以快轉時間，預設系統時間
+	Prober              Prober            // 健康檢查方式，預設 ping
+	DetectWindow        int               // 以最近幾個 Redis 指令的結果判斷是否降級，預設 0 只依重試結果
+	DetectErrorRate     float64           // DetectWindow 中失敗比例達此值時切換至降級模式，預設 0.5
+	DetectLatency       time.Duration     // 超過此時間的指令視為失敗，預設 0 不計延遲
+	DiskErrorBudget     int               // 檔案寫入連續失敗幾次後改為只使用記憶體，預設 10
+	OnEvent             func(Event)       // 模式切換與磁碟狀態變化的通知，依序於另一個 goroutine 呼叫，預設無
+	RecoveryTTL         string            // 復原時金鑰已存在於 Redis 的存活時間：longer / shorter，預設以本地值覆蓋
+	NotifyAfter         time.Duration     // 降級持續超過此時間才發出通知與 Email，預設 0 立即通知
+	PromoteAfter        int               // 本地檔案在一個清理週期（30 秒）內被讀取幾次後才放入記憶體層，預設 0 每次讀取都放入
+	PathResolver        PathResolver      // 金鑰對應的本地檔案路徑，預設 MD5 三層分片
+	Namespaces          map[string]string // 命名空間名稱對應金鑰前綴，Status 與 statsd 依此分別統計，預設無
+	Quotas              map[string]Quota  // 命名空間名稱對應降級寫入的配額，超過時拒絕或淘汰，預設無
+	TimeFormat          string            // time.Time 的儲存格式：rfc3339 / unixmilli，讀取時還原為 time.Time，預設 encoding/json 讀回字串
+	Compression         string            // 本地檔案的壓縮器 id，內建 gzip，其他以 RegisterCompressor 註冊，預設不壓縮
+	DedupWindow         time.Duration     // 值與 TTL 都與記憶體層相同且在此時間內寫入過的 Set 略過寫入，預設 0 不啟用
+	MaxFallback         time.Duration     // 降級持續超過此時間時發出 EventFallbackExpired 與 Email，預設 0 不限制
+	FallbackPolicy      string            // 超過 MaxFallback 後的行為：open 持續運作 / closed 拒絕寫入，預設 open
+	NilValue            string            // Set 傳入 nil 的處理方式：reject / store / delete，預設 reject 回傳 ErrNilValue
+	MigrateTo           *Redis            // 遷移目標，寫入同時送往此 Redis，讀取仍使用原本的 Redis 並與其比對，預設關閉
+	HotSet              int               // 定期記錄最近讀取的金鑰數，重新啟動後依序從 Redis 或本地檔案載入記憶體層，預設 0 不啟用
+	PublishBuffer       int               // 降級期間每個頻道緩衝的訊息數上限，預設 1000
+	PublishDrop         string            // 緩衝已滿時的處理方式：oldest 丟棄最舊的訊息 / newest 拒絕新的訊息，預設 oldest
+	ZSetMerge           string            // 復原時有序集合的分數合併方式：max 保留較大的分數 / local 以本地分數覆蓋，預設 local
+}
+
+type StatsD struct {
+	Address  string        // statsd / Datadog agent 位址，例如 127.0.0.1:8125
+	Prefix   string        // 指標前綴，預設 redis_fallback
+	Interval time.Duration // 推送間隔，預設 10 秒
 }
 
 type RedisFallback struct {
-	config       Config
-	logger       *Logger
-	redis        *redis.Client
-	context      context.Context
-	mutex        sync.RWMutex
-	cache        sync.Map
-	isHealth     bool
-	isRecovering atomic.Bool
-	checker      *time.Ticker
-	writer       *Writer
+	config        Config
+	logger        *logger
+	redis         redis.UniversalClient
+	nodes         *nodeHealth
+	context       context.Context
+	mutex         sync.RWMutex
+	cache         sync.Map
+	sizes         sync.Map
+	memoryBytes   atomic.Int64
+	isHealth      bool
+	isRecovering  atomic.Bool
+	goroutines    atomic.Int32
+	checker       Ticker
+	writer        *Writer
+	bloom         *bloomFilter
+	marshalers    *marshalers
+	index         *keyIndex
+	auditor       *auditor
+	metrics       metrics
+	modeSince     atomic.Int64
+	closed        chan struct{}
+	sharedChecker bool
+	isReadOnly    atomic.Bool
+	scripts       scripts
+	offlineWrites atomic.Int64
+	statsMutex    sync.Mutex
+	inflight      inflight
+	recoverySkip  sync.Map
+	dirty         sync.Map // 降級期間寫入的金鑰 -> 寫入序號，復原時只同步這些金鑰
+	dirtySeq      atomic.Int64
+	tombstones    sync.Map // 降級期間 Del 的刪除紀錄，磁碟無法寫入時仍可於復原時同步
+	alerted       atomic.Bool
+	transitions   transitions
+	lastPing      atomic.Int64
+	hashes        hashes
+	loaders       loaders
+	conditional   conditional
+	lists         lists
+	members       members
+	zsets         zsets
+	hot           hotSet
+	pubsub        pubsub
+	detector      *detector
+	expireHooks   expireHooks
+	events        events
+	incrs         incrs
+	access        accessCounter
+	namespaces    *namespaces
+	credentials   *credentials
+	migration     *migration
+	cleanupMutex  sync.RWMutex
+	failClosed    atomic.Bool
+	retryBudget   *retryBudget
 }
 
 type Writer struct {
-	config  Config
-	logger  *Logger
-	mutex   sync.Mutex
-	queue   chan WriteRequest
-	pending map[string]interface{}
-	timer   *time.Ticker
+	config       Config
+	logger       *logger
+	mutex        sync.Mutex
+	pending      map[string]WriteRequest
+	pendingBytes int64
+	written      map[string]time.Time
+	timer        Ticker
+	bloom        *bloomFilter
+	marshalers   *marshalers
+	index        *keyIndex
+	folders      sync.Map
+	folderMutex  sync.RWMutex
+	merged       atomic.Int64
+	dropped      atomic.Int64
+	slots        chan struct{}
+	diskFailures atomic.Int64
+	diskDown     atomic.Bool
+	onDiskDown   func(error)
+	kick         chan struct{}
+	replica      *replicaQueue
+	segments     *segments
 }
 
 type WriteRequest struct {
-	Key  string
-	Data interface{}
+	Key      string
+	Data     interface{}
+	Priority Priority
+	size     int64
 }
 
+// * 降級時寫入檔案的優先順序
+type Priority int
+
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+	PriorityLow
+)
+
 type Cache struct {
-	Key       string      `json:"key"`
-	Data      interface{} `json:"data"`
-	Type      string      `json:"type"`
-	Timestamp int64       `json:"timestamp"`
-	TTL       int64       `json:"ttl,omitempty"`
+	Key        string             `json:"key"`
+	Data       interface{}        `json:"data"`
+	Type       string             `json:"type"`
+	Timestamp  int64              `json:"timestamp"`
+	TTL        int64              `json:"ttl,omitempty"`
+	Delta      int64              `json:"delta,omitempty"`      // 計數器降級期間尚未補回 Redis 的差值
+	Removed    []string           `json:"removed,omitempty"`    // 雜湊降級期間刪除的欄位，復原時 HDEL
+	Head       int                `json:"head,omitempty"`       // 清單 Data 中由 LPush 推入的元素數
+	Increments map[string]float64 `json:"increments,omitempty"` // 有序集合降級期間 ZIncrBy 尚未補回 Redis 的差值
 }
 
 type Path struct {
@@ -96,6 +233,6 @@ type EmailConfig struct {
 	From     string                                 `json:"from"`
 	To       []string                               `json:"to"`
 	CC       []string                               `json:"cc"`
-	Subject  *func(ip string, reason string) string `json:"-"` // default: "[IP Sentry] IP {ip} has been banned"
-	Body     *func(ip string, reason string) string `json:"-"` // default: "[IP Sentry] IP {ip} has been banned for {reason}"
+	Subject  *func(ip string, reason string) string `json:"-"` // default: "[Redis Fallback] {ip} is unavailable"，設定 Name 時為 "[Redis Fallback: {name}]"
+	Body     *func(ip string, reason string) string `json:"-"` // default: "[Redis Fallback] {ip} is unavailable, running in {reason}"
 }
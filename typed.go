@@ -0,0 +1,100 @@
+package redisFallback
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+func (rf *RedisFallback) GetString(key string) (string, error) {
+	value, err := rf.Get(key)
+	if err != nil {
+		return "", err
+	}
+
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	return "", rf.typeError(key, value, "string")
+}
+
+// * 數字經過 JSON（檔案或 Redis）後為 float64，只接受整數值
+func (rf *RedisFallback) GetInt(key string) (int, error) {
+	value, err := rf.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int8:
+		return int(v), nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case uint8:
+		return int(v), nil
+	case uint16:
+		return int(v), nil
+	case uint32:
+		return int(v), nil
+	case uint64:
+		if v <= math.MaxInt {
+			return int(v), nil
+		}
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt && v < math.MaxInt {
+			return int(v), nil
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n), nil
+		}
+	}
+	return 0, rf.typeError(key, value, "int")
+}
+
+func (rf *RedisFallback) GetFloat(key string) (float64, error) {
+	value, err := rf.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case json.Number:
+		if n, err := v.Float64(); err == nil {
+			return n, nil
+		}
+	}
+	return 0, rf.typeError(key, value, "float64")
+}
+
+func (rf *RedisFallback) GetBool(key string) (bool, error) {
+	value, err := rf.Get(key)
+	if err != nil {
+		return false, err
+	}
+
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	return false, rf.typeError(key, value, "bool")
+}
+
+func (rf *RedisFallback) typeError(key string, value interface{}, want string) error {
+	return newOpError(rf.logger, "get", key, TierMemory, fmt.Errorf("%w: %T is not %s", ErrType, value, want))
+}
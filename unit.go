@@ -6,15 +6,28 @@ import (
 	"path/filepath"
 	"strconv"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func getPath(config Config, key string) Path {
+	if resolver := config.Options.PathResolver; resolver != nil {
+		if rel, ok := resolvePath(resolver, key); ok {
+			path := filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB), rel)
+			return Path{
+				folderPath: filepath.Dir(path),
+				filepath:   path,
+				filename:   filepath.Base(path),
+			}
+		}
+	}
+
 	encode := fmt.Sprintf("%x", md5.Sum([]byte(key)))
 	layer1 := encode[0:2]
 	layer2 := encode[2:4]
 	layer3 := encode[4:6]
 	filename := encode + ".json"
-	folderPath := filepath.Join(config.Option.DBPath, strconv.Itoa(config.Redis.DB), layer1, layer2, layer3)
+	folderPath := filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB), layer1, layer2, layer3)
 
 	return Path{
 		folderPath: folderPath,
@@ -23,9 +36,36 @@ func getPath(config Config, key string) Path {
 	}
 }
 
-func isExpired(item Cache) bool {
+func modeName(isHealth bool) string {
+	if isHealth {
+		return "normal"
+	}
+	return "fallback"
+}
+
+// * 以絕對到期時間寫入 Redis（SET ... EXAT）
+func setArgs(item Cache) redis.SetArgs {
+	if item.TTL <= 0 {
+		return redis.SetArgs{}
+	}
+	return redis.SetArgs{ExpireAt: time.Unix(item.Timestamp+item.TTL, 0)}
+}
+
+// * 剩餘存活時間，0 代表不過期
+func remainingTTL(item Cache, now time.Time) time.Duration {
+	if item.TTL <= 0 {
+		return 0
+	}
+	remaining := time.Duration(item.Timestamp+item.TTL-now.Unix()) * time.Second
+	if remaining <= 0 {
+		return time.Second
+	}
+	return remaining
+}
+
+func isExpired(item Cache, now time.Time) bool {
 	if item.TTL <= 0 {
 		return false
 	}
-	return time.Now().Unix() > item.Timestamp+item.TTL
+	return now.Unix() > item.Timestamp+item.TTL
 }
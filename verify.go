@@ -0,0 +1,142 @@
+package redisFallback
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	VerifyRepair     = "repair"     // 移除過期、損毀與孤立的檔案
+	VerifyQuarantine = "quarantine" // 過期檔案移除，損毀與孤立的檔案移至 {DBPath}/quarantine/{db}
+)
+
+const (
+	fileValid      = iota
+	fileExpired    // 已過期
+	fileCorrupt    // 無法解析
+	fileOrphaned   // 內容的金鑰與檔名不符
+	fileUnreadable // 無法讀取
+)
+
+type VerifyReport struct {
+	Checked  int      `json:"checked"`
+	Valid    int      `json:"valid"`
+	Expired  []string `json:"expired"`
+	Corrupt  []string `json:"corrupt"`
+	Orphaned []string `json:"orphaned"`
+	Fixed    int      `json:"fixed"` // 已移除或隔離的檔案數
+}
+
+// * 掃描本地檔案並回報過期、損毀與孤立的項目，action 為空時只回報
+func (rf *RedisFallback) Verify(action string) (VerifyReport, error) {
+	rf.writer.checkpoint()
+	return verifyFiles(rf.config, rf.marshalers, rf.now(), action, func(item Cache) {
+		rf.deleteCache(item.Key)
+		rf.index.remove(item.Key)
+	})
+}
+
+// * 不需建立實例（不連線 Redis），供命令列工具使用
+func Verify(dbPath string, db int, action string) (VerifyReport, error) {
+	config := Config{
+		Redis:   &Redis{DB: db},
+		Options: &Options{DBPath: dbPath},
+	}
+	config.Options = validOptionData(config)
+	if _, err := checkpointSegments(config); err != nil {
+		return VerifyReport{}, err
+	}
+	return verifyFiles(config, &marshalers{}, config.Options.Clock.Now(), action, nil)
+}
+
+func verifyFiles(config Config, m *marshalers, now time.Time, action string, onExpired func(Cache)) (VerifyReport, error) {
+	report := VerifyReport{}
+	if action != "" && action != VerifyRepair && action != VerifyQuarantine {
+		return report, fmt.Errorf("Unknown verify action: %s", action)
+	}
+
+	folderPath := filepath.Join(config.Options.DBPath, strconv.Itoa(config.Redis.DB))
+	quarantinePath := filepath.Join(config.Options.DBPath, "quarantine", strconv.Itoa(config.Redis.DB))
+
+	err := filepath.WalkDir(folderPath, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			return nil
+		}
+
+		report.Checked++
+		item, status := classifyFile(config, m, path, now)
+		switch status {
+		case fileValid:
+			report.Valid++
+			return nil
+		case fileExpired:
+			report.Expired = append(report.Expired, path)
+			if onExpired != nil {
+				onExpired(item)
+			}
+		case fileCorrupt, fileUnreadable:
+			report.Corrupt = append(report.Corrupt, path)
+		case fileOrphaned:
+			report.Orphaned = append(report.Orphaned, path)
+		}
+
+		if action == "" {
+			return nil
+		}
+		// * Expired files carry no information worth keeping
+		if action == VerifyRepair || status == fileExpired {
+			if os.Remove(path) == nil {
+				report.Fixed++
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(folderPath, path)
+		if err != nil {
+			return nil
+		}
+		target := filepath.Join(quarantinePath, rel)
+		if err := os.MkdirAll(filepath.Dir(target), config.Options.DirMode); err != nil {
+			return err
+		}
+		if os.Rename(path, target) == nil {
+			report.Fixed++
+		}
+		return nil
+	})
+
+	return report, err
+}
+
+func classifyFile(config Config, m *marshalers, path string, now time.Time) (Cache, int) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Cache{}, fileUnreadable
+	}
+
+	item, err := decodeCache(config, m, data)
+	if err != nil {
+		return item, fileCorrupt
+	}
+
+	// * Content does not belong to this path
+	if getPath(config, item.Key).filepath != path {
+		return item, fileOrphaned
+	}
+
+	if isExpired(item, now) {
+		return item, fileExpired
+	}
+	return item, fileValid
+}
@@ -0,0 +1,74 @@
+package redisFallback
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultWarmWorker = 8 // Warm 同時執行 loader 的最大數量
+
+// * 回傳金鑰的值與存活時間，回傳錯誤時略過該金鑰
+type WarmFunc func(key string) (interface{}, time.Duration, error)
+
+type WarmReport struct {
+	Loaded  int              `json:"loaded"`  // 已寫入的金鑰數
+	Skipped int              `json:"skipped"` // ctx 結束時尚未載入的金鑰數
+	Failed  map[string]error `json:"-"`       // loader 或寫入失敗的金鑰
+}
+
+// * 以有限的並行數對每個金鑰執行 loader 並寫入，Redis 正常時寫入 Redis，降級時寫入本地；供啟動時預熱快取
+// * ctx 結束時不再派發新的金鑰，已開始的 loader 會執行完畢
+func (rf *RedisFallback) Warm(ctx context.Context, keys []string, loader WarmFunc) (WarmReport, error) {
+	report := WarmReport{Failed: make(map[string]error)}
+	var mutex sync.Mutex
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(defaultWarmWorker, len(keys)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				err := rf.warmKey(key, loader)
+
+				mutex.Lock()
+				if err != nil {
+					report.Failed[key] = err
+				} else {
+					report.Loaded++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	sent := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- key:
+			sent++
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	rf.logger.Info("Warmed cache", "loaded", report.Loaded, "failed", len(report.Failed))
+	if sent < len(keys) {
+		report.Skipped = len(keys) - sent
+		return report, ctxError("warm", "", TierRedis, ctx.Err())
+	}
+	return report, nil
+}
+
+func (rf *RedisFallback) warmKey(key string, loader WarmFunc) error {
+	value, ttl, err := loader(key)
+	if err != nil {
+		return err
+	}
+	return rf.Set(key, value, ttl)
+}
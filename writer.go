@@ -1,27 +1,108 @@
 package redisFallback
 
 import (
-	"encoding/json"
 	"os"
 	"sync"
 )
 
 func (w *Writer) start() {
-	go func() {
-		for {
-			select {
-			case req := <-w.queue:
-				w.mutex.Lock()
-				w.pending[req.Key] = req.Data
-				w.mutex.Unlock()
-			case <-w.timer.C:
-				w.write()
-			}
+	for {
+		select {
+		case <-w.timer.C():
+		case <-w.kick:
+		}
+		w.write()
+	}
+}
+
+// * 每個金鑰只保留最新的值，佇列滿時回傳 false 由呼叫端直接寫入
+func (w *Writer) push(req WriteRequest) bool {
+	// * Nothing would ever drain the queue
+	if !fallbackDisk {
+		return false
+	}
+	if w.config.Options.FlushBytes > 0 {
+		if item, ok := req.Data.(Cache); ok {
+			req.size = estimateSize(w.config.Options.Encoder, req.Key, item)
+		}
+	}
+
+	w.mutex.Lock()
+	if old, ok := w.pending[req.Key]; ok {
+		w.merged.Add(1)
+		w.pendingBytes -= old.size
+	} else if len(w.pending) >= w.config.Options.MaxQueue {
+		w.mutex.Unlock()
+		w.dropped.Add(1)
+		return false
+	}
+	w.pending[req.Key] = req
+	w.pendingBytes += req.size
+	full := w.isFull()
+	w.mutex.Unlock()
+
+	// * Burst reached FlushSize / FlushBytes, write now instead of waiting for the ticker
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// * 需持有 mutex
+func (w *Writer) isFull() bool {
+	if size := w.config.Options.FlushSize; size > 0 && len(w.pending) >= size {
+		return true
+	}
+	if bytes := w.config.Options.FlushBytes; bytes > 0 && w.pendingBytes >= bytes {
+		return true
+	}
+	return false
+}
+
+// * 取出最多 limit 筆待寫入資料，由呼叫端負責寫入
+func (w *Writer) take(limit int) []WriteRequest {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	list := make([]WriteRequest, 0, min(limit, len(w.pending)))
+	for key, req := range w.pending {
+		if len(list) >= limit {
+			break
 		}
-	}()
+		list = append(list, req)
+		delete(w.pending, key)
+		w.pendingBytes -= req.size
+	}
+	return list
+}
+
+// * 移除待寫入的金鑰，由呼叫端改為直接寫入
+func (w *Writer) remove(key string) {
+	w.pop(key)
+}
+
+// * 取出並移除尚未寫入的請求
+func (w *Writer) pop(key string) (WriteRequest, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	old, ok := w.pending[key]
+	if ok {
+		delete(w.pending, key)
+		w.pendingBytes -= old.size
+	}
+	return old, ok
 }
 
 func (w *Writer) write() {
+	// * Memory only, keep pending writes until the disk is back
+	if w.diskDown.Load() {
+		return
+	}
+
 	w.mutex.Lock()
 	// * nothing to write
 	if len(w.pending) == 0 {
@@ -29,38 +110,216 @@ func (w *Writer) write() {
 		return
 	}
 
-	list := make(map[string]interface{})
-	for k, v := range w.pending {
-		list[k] = v
+	// * split by priority, higher priority is flushed first
+	now := w.config.Options.Clock.Now()
+	debounce := w.config.Options.Debounce
+	deferred := make(map[string]WriteRequest)
+	var deferredBytes int64
+	lists := make(map[Priority][]WriteRequest)
+	for key, req := range w.pending {
+		// * Hot key written recently, keep the latest value for a later tick
+		if last, ok := w.written[key]; ok && now.Sub(last) < debounce {
+			deferred[key] = req
+			deferredBytes += req.size
+			continue
+		}
+		lists[req.Priority] = append(lists[req.Priority], req)
+		if debounce > 0 {
+			w.written[key] = now
+		}
+	}
+	for key, last := range w.written {
+		if now.Sub(last) >= debounce {
+			delete(w.written, key)
+		}
+	}
+	w.pending = deferred
+	w.pendingBytes = deferredBytes
+	w.mutex.Unlock()
+
+	w.flushByPriority(lists)
+}
+
+// * 立即寫入所有待寫入資料，不受 Debounce 限制，回傳寫入筆數
+func (w *Writer) flushAll() int {
+	if w.diskDown.Load() {
+		return 0
+	}
+
+	w.mutex.Lock()
+	count := len(w.pending)
+	lists := make(map[Priority][]WriteRequest)
+	for _, req := range w.pending {
+		lists[req.Priority] = append(lists[req.Priority], req)
 	}
-	w.pending = make(map[string]interface{})
+	w.pending = make(map[string]WriteRequest)
+	w.pendingBytes = 0
 	w.mutex.Unlock()
 
+	w.flushByPriority(lists)
+	return count
+}
+
+func (w *Writer) flushByPriority(lists map[Priority][]WriteRequest) {
+	for _, priority := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		if len(lists[priority]) > 0 {
+			w.flush(lists[priority])
+		}
+	}
+}
+
+func (w *Writer) flush(list []WriteRequest) {
+	// * group writes by first level shard, one batch (and one segment append) per shard
+	shards := make(map[string][]WriteRequest)
+	for _, req := range list {
+		shard := segmentPath(w.config, req.Key)
+		shards[shard] = append(shards[shard], req)
+	}
+
+	// * bounded worker pool instead of one goroutine per key
+	workers := w.config.Options.MaxWorker
+	if workers > len(shards) {
+		workers = len(shards)
+	}
+
+	jobs := make(chan []WriteRequest, len(shards))
+	for _, batch := range shards {
+		jobs <- batch
+	}
+	close(jobs)
+
 	var wg sync.WaitGroup
-	for key, data := range list {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go func(k string, d interface{}) {
+		go func() {
 			defer wg.Done()
-			if item, ok := d.(Cache); ok {
-				w.writeToFile(k, item)
+			for batch := range jobs {
+				w.writeBatch(batch)
 			}
-		}(key, data)
+		}()
 	}
 	wg.Wait()
 }
 
+func (w *Writer) writeBatch(batch []WriteRequest) {
+	// * Shares MaxWriteConcurrency with direct writes, taken before folderMutex like writeToFile
+	w.slots <- struct{}{}
+	defer func() { <-w.slots }()
+
+	// * Keep the shard folder from being pruned while writing
+	w.folderMutex.RLock()
+	defer w.folderMutex.RUnlock()
+
+	if w.segments != nil {
+		for _, req := range w.writeSegment(batch) {
+			w.bloom.addKey(req.Key)
+			w.indexItem(req.Data.(Cache))
+		}
+		return
+	}
+
+	for _, req := range batch {
+		item, ok := req.Data.(Cache)
+		if !ok {
+			continue
+		}
+
+		if err := w.beforeFileWrite(req.Key); err != nil {
+			w.logger.Error(err, "Failed to write file")
+			w.diskResult(err)
+			continue
+		}
+
+		data, err := encodeFile(w.config, w.marshalers, item)
+		if err != nil {
+			w.logger.Error(err, "Failed to parse")
+			continue
+		}
+
+		path := getPath(w.config, req.Key)
+		// * Create fallback db directory, known folders are skipped
+		if err := w.ensureFolder(path.folderPath); err != nil {
+			w.logger.Error(err, "Failed to create folder")
+			w.diskResult(err)
+			continue
+		}
+
+		err = os.WriteFile(path.filepath, data, w.config.Options.FileMode)
+		w.diskResult(err)
+		if err != nil {
+			w.logger.Error(err, "Failed to write file")
+			continue
+		}
+		w.replicate(path.filepath, data)
+		w.bloom.addKey(req.Key)
+		w.indexItem(item)
+	}
+}
+
 func (w *Writer) writeToFile(key string, cache Cache) error {
 	path := getPath(w.config, key)
 
+	// * Not logged, avoid an error entry per write while the disk is down
+	if w.diskDown.Load() {
+		return &OpError{Op: "write", Key: key, Tier: TierFile, Err: ErrDiskUnavailable}
+	}
+
+	if err := w.beforeFileWrite(key); err != nil {
+		w.diskResult(err)
+		return newOpError(w.logger, "write", key, TierFile, err)
+	}
+
+	// * At most MaxWriteConcurrency file writes at once, a burst of callers cannot open unbounded files
+	w.slots <- struct{}{}
+	defer func() { <-w.slots }()
+
+	// * Keep the shard folder from being pruned while writing
+	w.folderMutex.RLock()
+	defer w.folderMutex.RUnlock()
+
 	// * Create fallback db directory
-	if err := os.MkdirAll(path.folderPath, 0755); err != nil {
-		return w.logger.Error(err, "Failed to create folder")
+	if err := w.ensureFolder(path.folderPath); err != nil {
+		w.diskResult(err)
+		return newOpError(w.logger, "write", key, TierFile, err)
+	}
+
+	data, err := encodeFile(w.config, w.marshalers, cache)
+	if err != nil {
+		return newOpError(w.logger, "write", key, TierFile, parseError(err))
 	}
 
-	data, err := json.Marshal(cache)
+	err = os.WriteFile(path.filepath, data, w.config.Options.FileMode)
+	w.diskResult(err)
 	if err != nil {
-		return w.logger.Error(err, "Failed to parse")
+		return newOpError(w.logger, "write", key, TierFile, err)
+	}
+	w.dropSegment(key)
+	w.replicate(path.filepath, data)
+	w.bloom.addKey(key)
+	w.indexItem(cache)
+
+	return nil
+}
+
+// * 刪除紀錄不列入索引，Scan 與 RandomKeys 不會回傳已刪除的金鑰
+func (w *Writer) indexItem(item Cache) {
+	if item.Type == tombstoneType {
+		w.index.remove(item.Key)
+		return
+	}
+	w.index.add(item.Key)
+}
+
+// * Skip MkdirAll for shard directories already known to exist
+func (w *Writer) ensureFolder(folderPath string) error {
+	if _, ok := w.folders.Load(folderPath); ok {
+		return nil
+	}
+
+	if err := os.MkdirAll(folderPath, w.config.Options.DirMode); err != nil {
+		return err
 	}
+	w.folders.Store(folderPath, struct{}{})
 
-	return os.WriteFile(path.filepath, data, 0644)
+	return nil
 }
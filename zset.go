@@ -0,0 +1,371 @@
+package redisFallback
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const zsetType = "zset" // 以 ZAdd 寫入的有序集合，記憶體中 Data 為 *zsetList，檔案中為成員對應分數的物件
+
+const (
+	ZSetMergeLocal = "local" // 復原時以本地分數覆蓋 Redis
+	ZSetMergeMax   = "max"   // 復原時保留較大的分數（ZADD GT），適用排行榜
+)
+
+type zsets struct {
+	mutex sync.Mutex
+}
+
+// * 設定成員的分數，回傳新加入的成員數；降級時寫入本地有序集合，復原時依 ZSetMerge 合併
+func (rf *RedisFallback) ZAdd(key string, scores map[string]float64) (int, error) {
+	if err := rf.validateKey("zadd", key); err != nil {
+		return 0, err
+	}
+	if err := rf.checkWritable("zadd", key); err != nil {
+		return 0, err
+	}
+	if len(scores) == 0 {
+		return 0, nil
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var count int64
+			count, err = rf.redis.ZAdd(ctx, key, zsetArgs(scores)...).Result()
+			if rf.stopRetry(err) {
+				break
+			}
+			if err == nil {
+				if !rf.config.Options.DisableMirror {
+					rf.updateZSet(key, scores, false, false)
+				}
+				return int(count), nil
+			}
+		}
+
+		if err := rf.redisFailed("zadd", key, err); err != nil {
+			return 0, err
+		}
+	}
+
+	_, count, err := rf.updateZSet(key, scores, false, true)
+	return count, err
+}
+
+// * 成員分數加上 increment，回傳新的分數；降級時為本地分數
+func (rf *RedisFallback) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	if err := rf.validateKey("zincrby", key); err != nil {
+		return 0, err
+	}
+	if err := rf.checkWritable("zincrby", key); err != nil {
+		return 0, err
+	}
+
+	if rf.isHealthy() && !rf.isReadOnly.Load() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var score float64
+			score, err = rf.redis.ZIncrBy(ctx, key, increment, member).Result()
+			if rf.stopRetry(err) {
+				break
+			}
+			if err == nil {
+				if !rf.config.Options.DisableMirror {
+					rf.updateZSet(key, map[string]float64{member: score}, false, false)
+				}
+				return score, nil
+			}
+		}
+
+		if err := rf.redisFailed("zincrby", key, err); err != nil {
+			return 0, err
+		}
+	}
+
+	return rf.zincrLocal(key, increment, member)
+}
+
+// * 取得成員的分數
+func (rf *RedisFallback) ZScore(key string, member string) (float64, error) {
+	if err := rf.validateKey("zscore", key); err != nil {
+		return 0, err
+	}
+
+	rf.metrics.gets.Add(1)
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var score float64
+			score, err = rf.redis.ZScore(ctx, key, member).Result()
+			if err == redis.Nil {
+				rf.metrics.misses.Add(1)
+				return 0, newOpError(rf.logger, "zscore", key, TierRedis, ErrNotFound)
+			}
+			if err == nil {
+				rf.metrics.hit(TierRedis)
+				return score, nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("zscore", key, err); err != nil {
+			return 0, err
+		}
+	}
+
+	list, err := rf.localZSet("zscore", key)
+	if err != nil {
+		rf.metrics.misses.Add(1)
+		return 0, err
+	}
+	score, ok := list.scores[member]
+	if !ok {
+		rf.metrics.misses.Add(1)
+		return 0, newOpError(rf.logger, "zscore", key, TierMemory, ErrNotFound)
+	}
+	return score, nil
+}
+
+// * 依分數由小到大取得 start 到 stop 的成員（含 stop），負數由結尾起算；同分時依成員字母排序
+func (rf *RedisFallback) ZRange(key string, start int64, stop int64) ([]string, error) {
+	if err := rf.validateKey("zrange", key); err != nil {
+		return nil, err
+	}
+
+	rf.metrics.gets.Add(1)
+
+	if rf.isHealthy() {
+		ctx := context.Background()
+		var err error
+		for i := 0; rf.canRetry(i); i++ {
+			var result []string
+			result, err = rf.redis.ZRange(ctx, key, start, stop).Result()
+			if err == nil {
+				rf.metrics.hit(TierRedis)
+				return result, nil
+			}
+			if rf.stopRetry(err) {
+				break
+			}
+		}
+
+		if err := rf.redisFailed("zrange", key, err); err != nil {
+			return nil, err
+		}
+	}
+
+	list, err := rf.localZSet("zrange", key)
+	if err != nil {
+		return nil, err
+	}
+	from, to := listRange(int64(list.length), start, stop)
+	if from > to {
+		return []string{}, nil
+	}
+	return list.rangeByRank(int(from), int(to)), nil
+}
+
+func (rf *RedisFallback) zincrLocal(key string, increment float64, member string) (float64, error) {
+	list, _, err := rf.updateZSet(key, map[string]float64{member: increment}, true, true)
+	return list.scores[member], err
+}
+
+// * 複製後設定成員分數，incr 為 true 時累加，保留原本的到期時間，回傳更新後的有序集合與新加入的成員數
+// * persist 為 true 時寫入本地檔案；本地沒有分數的成員以 Increments 記錄累加的差值，復原時以 ZINCRBY 補回
+func (rf *RedisFallback) updateZSet(key string, scores map[string]float64, incr bool, persist bool) (*zsetList, int, error) {
+	rf.zsets.mutex.Lock()
+	defer rf.zsets.mutex.Unlock()
+
+	item := Cache{Key: key, Type: zsetType, Timestamp: rf.now().Unix()}
+	list := newZSetList()
+	increments := make(map[string]float64)
+
+	if old, ok := rf.localItem(key); ok && old.Type == zsetType {
+		if old.TTL > 0 {
+			item.TTL = max(old.Timestamp+old.TTL-item.Timestamp, 1)
+		}
+		list = copyZSet(old)
+		// * Redis already applied them while healthy, unless recovery has not synced them yet
+		if persist || rf.isDirty(key) {
+			for member, delta := range old.Increments {
+				increments[member] = delta
+			}
+		}
+	}
+
+	count := 0
+	for member, score := range scores {
+		old, ok := list.scores[member]
+		if !ok {
+			count++
+		}
+		if incr {
+			// * Score unknown offline, recovery must add to the Redis score instead of replacing it
+			if _, pending := increments[member]; persist && (pending || !ok) {
+				increments[member] += score
+			}
+			score += old
+		} else if persist {
+			// * Set after the increments, the new score replaces the Redis one
+			delete(increments, member)
+		}
+		list.set(member, score)
+	}
+	item.Data = list
+	if len(increments) > 0 {
+		item.Increments = increments
+	}
+
+	if !persist {
+		rf.storeCache(key, item)
+		return list, count, nil
+	}
+	return list, count, rf.setToMemory(context.Background(), key, item, PriorityNormal)
+}
+
+// * 本地有序集合，回傳的版本不會再被修改
+func (rf *RedisFallback) localZSet(op string, key string) (*zsetList, error) {
+	rf.zsets.mutex.Lock()
+	item, ok := rf.localItem(key)
+	rf.zsets.mutex.Unlock()
+	if !ok {
+		return nil, newOpError(rf.logger, op, key, TierMemory, ErrNotFound)
+	}
+	if item.Type != zsetType {
+		return nil, newOpError(rf.logger, op, key, TierMemory, fmt.Errorf("%w: %T is not a sorted set", ErrType, item.Data))
+	}
+	if list, ok := item.Data.(*zsetList); ok {
+		return list, nil
+	}
+	return copyZSet(item), nil
+}
+
+// * 可修改的複本；記憶體中為 *zsetList，經過 JSON（檔案）後為 map[string]interface{}
+func copyZSet(item Cache) *zsetList {
+	switch data := item.Data.(type) {
+	case *zsetList:
+		return data.clone()
+	case map[string]float64:
+		list := newZSetList()
+		for member, score := range data {
+			list.set(member, score)
+		}
+		return list
+	case map[string]interface{}:
+		list := newZSetList()
+		for member, value := range data {
+			if score, ok := value.(float64); ok {
+				list.set(member, score)
+			}
+		}
+		return list
+	}
+	return newZSetList()
+}
+
+// * 以分數寫入的成員，不含只記錄差值的成員
+func zsetScores(item Cache) map[string]float64 {
+	scores := make(map[string]float64)
+	copyZSet(item).each(func(member string, score float64) bool {
+		if _, ok := item.Increments[member]; !ok {
+			scores[member] = score
+		}
+		return true
+	})
+	return scores
+}
+
+func zsetArgs(scores map[string]float64) []redis.Z {
+	args := make([]redis.Z, 0, len(scores))
+	for member, score := range scores {
+		args = append(args, redis.Z{Score: score, Member: member})
+	}
+	return args
+}
+
+// * ZSetMerge 為 max 時以 ZADD GT 寫入，只提高 Redis 中較小的分數；降級期間的 ZIncrBy 差值以 ZINCRBY 補回
+func (rf *RedisFallback) writeZSet(ctx context.Context, c redis.Cmdable, key string, item Cache) (redis.Cmder, error) {
+	var cmd redis.Cmder
+	if scores := zsetScores(item); len(scores) > 0 {
+		if rf.config.Options.ZSetMerge == ZSetMergeMax {
+			cmd = c.ZAddGT(ctx, key, zsetArgs(scores)...)
+		} else {
+			cmd = c.ZAdd(ctx, key, zsetArgs(scores)...)
+		}
+	}
+	for member, delta := range item.Increments {
+		cmd = c.ZIncrBy(ctx, key, delta, member)
+	}
+	if cmd == nil {
+		return nil, fmt.Errorf("%w: empty sorted set", ErrType)
+	}
+	return cmd, nil
+}
+
+// * ZADD 的參數，依分數排序，不含只記錄差值的成員
+func zsetRESP(item Cache) []string {
+	var args []string
+	copyZSet(item).each(func(member string, score float64) bool {
+		if _, ok := item.Increments[member]; !ok {
+			args = append(args, strconv.FormatFloat(score, 'g', -1, 64), member)
+		}
+		return true
+	})
+	return args
+}
+
+// * 差值已補回 Redis，扣除後保留復原期間新增的部分，避免下次復原重複累加
+func (rf *RedisFallback) settleZSet(key string, sent map[string]float64) {
+	if len(sent) == 0 {
+		return
+	}
+	rf.zsets.mutex.Lock()
+	defer rf.zsets.mutex.Unlock()
+
+	// * Queued file write still carries the old increments
+	rf.writer.remove(key)
+
+	cached, ok := rf.cache.Load(key)
+	if !ok {
+		return
+	}
+	item := cached.(Cache)
+	if item.Type != zsetType {
+		return
+	}
+	list := copyZSet(item)
+	increments := make(map[string]float64)
+	for member, delta := range item.Increments {
+		if left := delta - sent[member]; left != 0 {
+			increments[member] = left
+			continue
+		}
+		// * Local score only holds the increments, Redis has the real one
+		list.remove(member, list.scores[member])
+	}
+	item.Data = list
+	item.Increments = nil
+	if len(increments) > 0 {
+		item.Increments = increments
+	}
+	if list.length == 0 {
+		rf.deleteCache(key)
+		return
+	}
+	rf.storeCache(key, item)
+	// * Increments made during recovery, keep them on disk for the next one
+	if len(item.Increments) > 0 {
+		rf.enqueueWrite(WriteRequest{Key: key, Data: item})
+	}
+}